@@ -0,0 +1,462 @@
+package partial
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"reflect"
+	"sort"
+	"text/template/parse"
+)
+
+var (
+	// builtinFunctionNames are the functions predefined by text/template.
+	builtinFunctionNames = map[string]struct{}{
+		"and": {}, "or": {}, "not": {}, "len": {}, "index": {}, "slice": {}, "call": {},
+		"print": {}, "printf": {}, "println": {}, "html": {}, "js": {}, "urlquery": {},
+		"eq": {}, "ne": {}, "lt": {}, "le": {}, "gt": {}, "ge": {},
+	}
+
+	dataType = reflect.TypeOf(Data{})
+)
+
+type (
+	// Issue is a problem found by Check.
+	Issue struct {
+		// ID is the id of the partial
+		ID string
+		// Template is the template file
+		Template string
+		// Location is the position in the template, formatted as name:line:col
+		Location string
+		// Message describes the problem
+		Message string
+	}
+
+	// checker walks the parse trees of a single partial.
+	checker struct {
+		p        *Partial
+		file     string
+		files    map[string]string
+		funcs    map[string]struct{}
+		children map[string]struct{}
+		trees    map[string]*parse.Tree
+		checked  map[string]struct{}
+		names    map[string]struct{}
+		issues   []Issue
+	}
+
+	// checkScope tracks the type of dot and of the declared variables, nil means unknown.
+	checkScope struct {
+		dot  reflect.Type
+		vars map[string]reflect.Type
+	}
+)
+
+func (i Issue) String() string {
+	if i.Location != "" {
+		return fmt.Sprintf("%s: %s (partial '%s')", i.Location, i.Message, i.ID)
+	}
+	return fmt.Sprintf("%s: %s (partial '%s')", i.Template, i.Message, i.ID)
+}
+
+// SetDataType declares the type of the partial data, so Check can verify the .Data references of the templates.
+// The example value is only used for its type, typically a struct whose fields match the keys of the data map.
+func (p *Partial) SetDataType(example any) *Partial {
+	p.dataType = reflect.TypeOf(example)
+	return p
+}
+
+// Check parses the templates of the partial and all partials below it and reports
+// field references that do not exist on the declared data or props type, calls to unknown functions,
+// child calls with an id that is not registered on the partial and route calls with an unknown name or a missing param.
+// Wrappers of a layout only know their content once it is set, so check the partial the layout renders.
+func Check(p *Partial) []Issue {
+	var issues []Issue
+	visited := make(map[*Partial]struct{})
+	checkPartial(p, visited, &issues)
+	return issues
+}
+
+func checkPartial(p *Partial, visited map[*Partial]struct{}, issues *[]Issue) {
+	if p == nil {
+		return
+	}
+	if _, ok := visited[p]; ok {
+		return
+	}
+	visited[p] = struct{}{}
+
+	*issues = append(*issues, p.check()...)
+
+	for _, child := range p.getChildren() {
+		checkPartial(child, visited, issues)
+	}
+
+	if p.selection != nil {
+		keys := make([]string, 0, len(p.selection.Partials))
+		for k := range p.selection.Partials {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			selected := p.selection.Partials[k]
+			if selected != nil && selected.parent == nil {
+				selected.parent = p
+			}
+			checkPartial(selected, visited, issues)
+		}
+	}
+}
+
+// check reports the issues of the templates of the partial itself.
+func (p *Partial) check() []Issue {
+	funcs := make(map[string]struct{})
+	for name := range p.clone().getFuncs(&Data{}) {
+		funcs[name] = struct{}{}
+	}
+
+	p.mu.RLock()
+	children := make(map[string]struct{}, len(p.children))
+	for id := range p.children {
+		children[id] = struct{}{}
+	}
+	p.mu.RUnlock()
+
+	c := &checker{
+		p:        p,
+		funcs:    funcs,
+		children: children,
+		trees:    make(map[string]*parse.Tree),
+		checked:  make(map[string]struct{}),
+		names:    make(map[string]struct{}),
+		files:    make(map[string]string),
+	}
+
+	fsys := p.getFS()
+	var entries []string
+	for _, name := range p.templates {
+		b, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			c.issues = append(c.issues, Issue{ID: p.id, Template: name, Message: fmt.Sprintf("error reading template: %v", err)})
+			continue
+		}
+
+		t := parse.New(path.Base(name))
+		t.Mode = parse.SkipFuncCheck
+		if _, err = t.Parse(string(b), "", "", c.trees); err != nil {
+			c.issues = append(c.issues, Issue{ID: p.id, Template: name, Message: err.Error()})
+			continue
+		}
+		entries = append(entries, path.Base(name))
+		c.files[path.Base(name)] = name
+	}
+
+	for _, name := range entries {
+		c.checkTree(name, dataType)
+	}
+
+	// templates that are never invoked with a known dot are checked with an unknown dot
+	names := make([]string, 0, len(c.trees))
+	for name := range c.trees {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if _, ok := c.names[name]; !ok {
+			c.checkTree(name, nil)
+		}
+	}
+
+	return c.issues
+}
+
+func (c *checker) checkTree(name string, dot reflect.Type) {
+	tree, ok := c.trees[name]
+	if !ok || tree.Root == nil {
+		return
+	}
+
+	// a template is checked once per type of dot it is invoked with
+	key := name + "\x00"
+	if dot != nil {
+		key += dot.String()
+	}
+	if _, ok = c.checked[key]; ok {
+		return
+	}
+	c.checked[key] = struct{}{}
+	c.names[name] = struct{}{}
+
+	c.file = c.files[tree.ParseName]
+	c.walk(tree, tree.Root, &checkScope{dot: dot, vars: map[string]reflect.Type{"$": dot}})
+}
+
+func (c *checker) walk(tree *parse.Tree, node parse.Node, scope *checkScope) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, item := range n.Nodes {
+			c.walk(tree, item, scope)
+		}
+	case *parse.ActionNode:
+		c.checkPipe(tree, n.Pipe, scope)
+	case *parse.IfNode:
+		c.checkPipe(tree, n.Pipe, scope)
+		c.walk(tree, n.List, scope.copy(scope.dot))
+		c.walk(tree, n.ElseList, scope.copy(scope.dot))
+	case *parse.WithNode:
+		t := c.checkPipe(tree, n.Pipe, scope)
+		c.walk(tree, n.List, scope.copy(t).declare(n.Pipe, t))
+		c.walk(tree, n.ElseList, scope.copy(scope.dot))
+	case *parse.RangeNode:
+		t := c.checkPipe(tree, n.Pipe, scope)
+		key, elem := rangeTypes(t)
+		inner := scope.copy(elem)
+		if len(n.Pipe.Decl) == 1 {
+			inner.vars[n.Pipe.Decl[0].Ident[0]] = elem
+		} else if len(n.Pipe.Decl) == 2 {
+			inner.vars[n.Pipe.Decl[0].Ident[0]] = key
+			inner.vars[n.Pipe.Decl[1].Ident[0]] = elem
+		}
+		c.walk(tree, n.List, inner)
+		c.walk(tree, n.ElseList, scope.copy(scope.dot))
+	case *parse.TemplateNode:
+		var t reflect.Type
+		if n.Pipe != nil {
+			t = c.checkPipe(tree, n.Pipe, scope)
+		}
+		c.checkTree(n.Name, t)
+	}
+}
+
+// checkPipe checks the commands of the pipeline and returns the type of its result.
+func (c *checker) checkPipe(tree *parse.Tree, pipe *parse.PipeNode, scope *checkScope) reflect.Type {
+	if pipe == nil {
+		return nil
+	}
+
+	var result reflect.Type
+	for _, cmd := range pipe.Cmds {
+		result = c.checkCommand(tree, cmd, scope)
+	}
+
+	if len(pipe.Cmds) != 1 {
+		result = nil
+	}
+
+	if !pipe.IsAssign {
+		scope.declare(pipe, result)
+	} else {
+		for _, v := range pipe.Decl {
+			scope.vars[v.Ident[0]] = nil
+		}
+	}
+
+	return result
+}
+
+func (c *checker) checkCommand(tree *parse.Tree, cmd *parse.CommandNode, scope *checkScope) reflect.Type {
+	var result reflect.Type
+	for i, arg := range cmd.Args {
+		var t reflect.Type
+		switch n := arg.(type) {
+		case *parse.IdentifierNode:
+			if _, ok := builtinFunctionNames[n.Ident]; !ok {
+				if _, ok = c.funcs[n.Ident]; !ok {
+					c.report(tree, n, fmt.Sprintf("function %q is not defined", n.Ident))
+				}
+			}
+			if i == 0 && n.Ident == "route" && len(cmd.Args) > 1 {
+				c.checkRoute(tree, cmd)
+			}
+			if i == 0 && n.Ident == "child" && len(cmd.Args) > 1 {
+				if id, ok := cmd.Args[1].(*parse.StringNode); ok {
+					if _, ok = c.children[id.Text]; !ok {
+						c.report(tree, id, fmt.Sprintf("child %q is not registered on partial '%s'", id.Text, c.p.id))
+					}
+				}
+			}
+		case *parse.FieldNode:
+			t = c.checkFields(tree, n, scope.dot, n.Ident)
+		case *parse.VariableNode:
+			t = c.checkFields(tree, n, scope.vars[n.Ident[0]], n.Ident[1:])
+		case *parse.DotNode:
+			t = scope.dot
+		case *parse.PipeNode:
+			c.checkPipe(tree, n, scope.copy(scope.dot))
+		case *parse.ChainNode:
+			if pipe, ok := n.Node.(*parse.PipeNode); ok {
+				c.checkPipe(tree, pipe, scope.copy(scope.dot))
+			}
+		}
+		if i == 0 {
+			result = t
+		}
+	}
+
+	if len(cmd.Args) != 1 {
+		return nil
+	}
+
+	return result
+}
+
+// checkRoute reports route calls with a literal name that is not registered or without a literal param of the route.
+// Without registered routes, e.g. when the templates are checked outside the application, nothing is reported.
+func (c *checker) checkRoute(tree *parse.Tree, cmd *parse.CommandNode) {
+	routes := c.p.getRoutes()
+	name, ok := cmd.Args[1].(*parse.StringNode)
+	if len(routes) == 0 || !ok {
+		return
+	}
+
+	pattern, ok := routes[name.Text]
+	if !ok {
+		c.report(tree, name, fmt.Sprintf("route %q is not registered", name.Text))
+		return
+	}
+
+	given := make(map[string]struct{})
+	for i := 2; i < len(cmd.Args); i += 2 {
+		key, ok := cmd.Args[i].(*parse.StringNode)
+		if !ok {
+			// the keys are only known when rendering
+			return
+		}
+		given[key.Text] = struct{}{}
+	}
+
+	for _, param := range routeParams(pattern) {
+		if _, ok = given[param]; !ok {
+			c.report(tree, name, fmt.Sprintf("route %q needs the param %q", name.Text, param))
+		}
+	}
+}
+
+// checkFields resolves the field chain on the type and reports the first field that does not exist.
+func (c *checker) checkFields(tree *parse.Tree, node parse.Node, t reflect.Type, fields []string) reflect.Type {
+	for i, field := range fields {
+		if t == nil {
+			return nil
+		}
+
+		next, ok, known := c.fieldType(t, fields[:i], field)
+		if !known {
+			return nil
+		}
+		if !ok {
+			c.report(tree, node, fmt.Sprintf("field %q does not exist on type %s", field, t))
+			return nil
+		}
+		t = next
+	}
+
+	return t
+}
+
+// fieldType returns the type of the field or method of t, known is false if it cannot be decided statically.
+func (c *checker) fieldType(t reflect.Type, parents []string, field string) (next reflect.Type, ok bool, known bool) {
+	// the data and props of the partial are replaced with their declared types
+	if t == dataType && len(parents) == 0 {
+		switch field {
+		case "Data":
+			if c.p.dataType != nil {
+				return c.p.dataType, true, true
+			}
+		case "Props":
+			if c.p.props != nil {
+				return reflect.TypeOf(c.p.props), true, true
+			}
+		}
+	}
+
+	if m, found := t.MethodByName(field); found {
+		return methodResult(m.Type), true, true
+	}
+	if t.Kind() != reflect.Pointer && t.Kind() != reflect.Interface {
+		if m, found := reflect.PointerTo(t).MethodByName(field); found {
+			return methodResult(m.Type), true, true
+		}
+	}
+
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		f, found := t.FieldByName(field)
+		if !found || !f.IsExported() {
+			return nil, false, true
+		}
+		return f.Type, true, true
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String {
+			return nil, false, true
+		}
+		return t.Elem(), true, true
+	case reflect.Interface:
+		// the dynamic type can have more methods than the interface
+		return nil, false, false
+	default:
+		return nil, false, true
+	}
+}
+
+func (c *checker) report(tree *parse.Tree, node parse.Node, message string) {
+	location, _ := tree.ErrorContext(node)
+	c.issues = append(c.issues, Issue{ID: c.p.id, Template: c.file, Location: location, Message: message})
+}
+
+func (s *checkScope) copy(dot reflect.Type) *checkScope {
+	vars := make(map[string]reflect.Type, len(s.vars))
+	for k, v := range s.vars {
+		vars[k] = v
+	}
+	return &checkScope{dot: dot, vars: vars}
+}
+
+func (s *checkScope) declare(pipe *parse.PipeNode, t reflect.Type) *checkScope {
+	if pipe == nil {
+		return s
+	}
+	for _, v := range pipe.Decl {
+		s.vars[v.Ident[0]] = t
+	}
+	return s
+}
+
+// methodResult returns the type of the first result of the method.
+func methodResult(m reflect.Type) reflect.Type {
+	if m.NumOut() == 0 {
+		return nil
+	}
+	return m.Out(0)
+}
+
+// rangeTypes returns the key and element types when ranging over t.
+func rangeTypes(t reflect.Type) (key, elem reflect.Type) {
+	if t == nil {
+		return nil, nil
+	}
+
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.Slice, reflect.Array:
+		return reflect.TypeOf(0), t.Elem()
+	case reflect.Map:
+		return t.Key(), t.Elem()
+	case reflect.Chan:
+		return nil, t.Elem()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return t, t
+	default:
+		return nil, nil
+	}
+}
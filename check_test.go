@@ -0,0 +1,61 @@
+package partial
+
+import (
+	"strings"
+	"testing"
+)
+
+type checkUser struct {
+	Name   string
+	Emails []string
+}
+
+type checkData struct {
+	Title string
+	User  *checkUser
+	Users []checkUser
+}
+
+func TestCheck(t *testing.T) {
+	fsys := &InMemoryFS{
+		Files: map[string]string{
+			"index.html": `<h1>{{ .Data.Title }}</h1>{{ .Data.Titel }}
+{{ child "content" }}{{ child "missing" }}
+{{ with .Data.User }}{{ .Name }}{{ .Nmae }}{{ end }}
+{{ range $i, $u := .Data.Users }}{{ $u.Name }}{{ $u.Age }}{{ range .Emails }}{{ upper . }}{{ end }}{{ end }}
+{{ template "footer" .Data.User }}{{ .URL.Path }}{{ .Loc.GetLocale }}{{ .Csrf.Token .Ctx }}{{ .Missing }}`,
+			"footer.html":  `{{ define "footer" }}<footer>{{ .Name }} {{ .Phone }}</footer>{{ end }}`,
+			"content.html": `<div>{{ unknownFunc .Props.Title }}{{ .Props.Body }}{{ safeHTML .Data.Anything.Goes }}</div>`,
+			"broken.html":  `{{ if }}`,
+		},
+	}
+
+	content := NewTypedID[articleProps]("content", "content.html")
+
+	root := New("index.html", "footer.html").ID("root").SetDataType(checkData{}).SetFileSystem(fsys)
+	root.With(content.Partial)
+	root.WithSelectMap("broken", map[string]*Partial{
+		"broken": New("broken.html").ID("broken"),
+	})
+
+	var got []string
+	for _, issue := range Check(root) {
+		got = append(got, issue.String())
+	}
+
+	expected := []string{
+		`index.html:1:34: field "Titel" does not exist on type partial.checkData (partial 'root')`,
+		`index.html:2:30: child "missing" is not registered on partial 'root' (partial 'root')`,
+		`index.html:3:35: field "Nmae" does not exist on type *partial.checkUser (partial 'root')`,
+		`index.html:4:51: field "Age" does not exist on type partial.checkUser (partial 'root')`,
+		`footer.html:1:44: field "Phone" does not exist on type *partial.checkUser (partial 'root')`,
+		`index.html:5:94: field "Missing" does not exist on type partial.Data (partial 'root')`,
+		`content.html:1:8: function "unknownFunc" is not defined (partial 'content')`,
+		`content.html:1:44: field "Body" does not exist on type partial.articleProps (partial 'content')`,
+		`broken.html: template: broken.html:1: missing value for if (partial 'broken')`,
+	}
+
+	if strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("expected issues\n%s\ngot\n%s", strings.Join(expected, "\n"), strings.Join(got, "\n"))
+	}
+}
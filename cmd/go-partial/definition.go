@@ -0,0 +1,179 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/partial-coffee/go-partial"
+)
+
+// decodeFile decodes a JSON or YAML file into v, the format is chosen by the extension.
+func decodeFile(name string, v any) error {
+	b, err := os.ReadFile(name)
+	if err != nil {
+		return err
+	}
+
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(b, v)
+	case ".json":
+		dec := json.NewDecoder(bytes.NewReader(b))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(v)
+	default:
+		return fmt.Errorf("%s: unsupported file type, use .json, .yaml or .yml", name)
+	}
+
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+
+	return nil
+}
+
+// loadDefinition reads the config file and verifies that the tree can be built.
+func loadDefinition(name string) (*partial.Definition, error) {
+	def, err := partial.LoadDefinition(os.DirFS(filepath.Dir(name)), filepath.Base(name))
+	if err != nil {
+		return nil, err
+	}
+
+	if err = validate(def, "", "", make(map[string]struct{})); err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+
+	if _, err = def.Build(stubRegistry(def)); err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+
+	return def, nil
+}
+
+// validate reports duplicate ids anywhere in the tree, path is the location of the parent used in the errors.
+// A selected partial without an id is named after its key, as Definition.Build does.
+func validate(d *partial.Definition, path, fallbackID string, ids map[string]struct{}) error {
+	if d == nil {
+		return fmt.Errorf("%s: empty child", path)
+	}
+
+	id := d.ID
+	if id == "" {
+		id = fallbackID
+	}
+	if id == "" {
+		return fmt.Errorf("%s/: partial has no id", path)
+	}
+
+	path += "/" + id
+	if _, ok := ids[id]; ok {
+		return fmt.Errorf("%s: duplicate partial id %q", path, id)
+	}
+	ids[id] = struct{}{}
+
+	for _, child := range append(append([]*partial.Definition{}, d.Children...), d.OOB...) {
+		if err := validate(child, path, "", ids); err != nil {
+			return err
+		}
+	}
+
+	if d.Selection != nil {
+		for _, key := range selectionKeys(d.Selection) {
+			if err := validate(d.Selection.Partials[key], path+"["+key+"]", key, ids); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// stubRegistry registers an action and a guard that do nothing for every name the definition refers to,
+// the tool cannot run the Go funcs of the application.
+func stubRegistry(def *partial.Definition) *partial.Registry {
+	reg := partial.NewRegistry()
+	walkDefinition(def, func(d *partial.Definition) {
+		for _, name := range []string{d.Action, d.TemplateAction} {
+			if name != "" {
+				reg.Action(name, func(ctx context.Context, p *partial.Partial, data *partial.Data) (*partial.Partial, error) {
+					return p, nil
+				})
+			}
+		}
+		for _, name := range d.Guards {
+			reg.Guard(name, func(ctx context.Context, p *partial.Partial, data *partial.Data) error {
+				return nil
+			})
+		}
+	})
+	return reg
+}
+
+// walkDefinition calls fn for the definition and every definition below it.
+func walkDefinition(d *partial.Definition, fn func(d *partial.Definition)) {
+	if d == nil {
+		return
+	}
+
+	fn(d)
+	for _, child := range d.Children {
+		walkDefinition(child, fn)
+	}
+	for _, child := range d.OOB {
+		walkDefinition(child, fn)
+	}
+	if d.Selection != nil {
+		for _, key := range selectionKeys(d.Selection) {
+			walkDefinition(d.Selection.Partials[key], fn)
+		}
+	}
+}
+
+func selectionKeys(s *partial.SelectionDefinition) []string {
+	keys := make([]string, 0, len(s.Partials))
+	for k := range s.Partials {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// funcsFlag collects the names of the application functions to stub.
+type funcsFlag []string
+
+func (f *funcsFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *funcsFlag) Set(value string) error {
+	for _, name := range strings.Split(value, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			*f = append(*f, name)
+		}
+	}
+	return nil
+}
+
+// funcMap returns the default functions extended with a stub for every application function.
+// The stubs accept any arguments and return an empty string.
+func (f funcsFlag) funcMap() template.FuncMap {
+	funcs := make(template.FuncMap, len(partial.DefaultTemplateFuncMap)+len(f))
+	for k, v := range partial.DefaultTemplateFuncMap {
+		funcs[k] = v
+	}
+	for _, name := range f {
+		if _, ok := funcs[name]; !ok {
+			funcs[name] = func(...any) string { return "" }
+		}
+	}
+	return funcs
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+	"text/template/parse"
+
+	"github.com/partial-coffee/go-partial"
+)
+
+const lintUsage = `usage: go-partial lint [flags] dir
+
+Parses every template under dir and reports parse errors, calls to unknown functions and
+child calls with an unknown id. Templates declared in the config are checked as part of
+the partial tree, so their child ids must be registered on the partial using the template.
+Other templates are checked on their own; their child ids must match the id of a partial
+declared in the config or the name of a template file without extension.
+
+flags:
+`
+
+func runLint(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("lint", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprint(stderr, lintUsage)
+		flags.PrintDefaults()
+	}
+
+	var funcs funcsFlag
+	config := flags.String("config", "", "JSON or YAML `file` declaring the partial tree")
+	ext := flags.String("ext", ".html,.gohtml,.tmpl", "comma separated `extensions` of the template files")
+	flags.Var(&funcs, "funcs", "comma separated `names` of application functions to stub, can be repeated")
+
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+
+	dir := flags.Arg(0)
+	fsys := os.DirFS(dir)
+	funcMap := funcs.funcMap()
+
+	var issues []partial.Issue
+	declared := make(map[string]struct{})
+	knownIDs := make(map[string]struct{})
+
+	if *config != "" {
+		def, err := loadDefinition(*config)
+		if err != nil {
+			fmt.Fprintf(stderr, "go-partial: %v\n", err)
+			return 2
+		}
+
+		root, err := def.Build(stubRegistry(def))
+		if err != nil {
+			fmt.Fprintf(stderr, "go-partial: %v\n", err)
+			return 2
+		}
+
+		partial.NewService(&partial.Config{FuncMap: funcMap, FS: fsys}).NewLayout().Set(root)
+		issues = append(issues, partial.Check(root)...)
+
+		walkDefinition(def, func(d *partial.Definition) {
+			for _, name := range d.Templates {
+				declared[name] = struct{}{}
+			}
+			if d.ID != "" {
+				knownIDs[d.ID] = struct{}{}
+			}
+		})
+	}
+
+	names, err := templateFiles(fsys, strings.Split(*ext, ","))
+	if err != nil {
+		fmt.Fprintf(stderr, "go-partial: %v\n", err)
+		return 2
+	}
+
+	for _, name := range names {
+		knownIDs[strings.TrimSuffix(path.Base(name), path.Ext(name))] = struct{}{}
+	}
+
+	for _, name := range names {
+		if _, ok := declared[name]; ok {
+			continue
+		}
+
+		p := partial.NewID(name, name).SetFileSystem(fsys)
+		p.MergeFuncMap(funcMap)
+
+		// register the known ids, the check reports the others as unknown children
+		for _, id := range childIDs(fsys, name) {
+			if _, ok := knownIDs[id]; ok {
+				p.With(partial.NewID(id))
+			}
+		}
+
+		issues = append(issues, partial.Check(p)...)
+	}
+
+	for _, issue := range issues {
+		fmt.Fprintln(stdout, issue)
+	}
+
+	if len(issues) > 0 {
+		fmt.Fprintf(stderr, "%d problem(s) found\n", len(issues))
+		return 1
+	}
+
+	return 0
+}
+
+// templateFiles returns the files of the filesystem with one of the extensions, in lexical order.
+func templateFiles(fsys fs.FS, extensions []string) ([]string, error) {
+	var names []string
+	err := fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		for _, ext := range extensions {
+			if ext = strings.TrimSpace(ext); ext != "" && path.Ext(name) == ext {
+				names = append(names, name)
+				break
+			}
+		}
+		return nil
+	})
+	return names, err
+}
+
+// childIDs returns the ids passed as literal to the child functions of the template.
+// Templates that cannot be read or parsed return no ids, the error is reported by the check.
+func childIDs(fsys fs.FS, name string) []string {
+	b, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return nil
+	}
+
+	trees := make(map[string]*parse.Tree)
+	t := parse.New(name)
+	t.Mode = parse.SkipFuncCheck
+	if _, err = t.Parse(string(b), "", "", trees); err != nil {
+		return nil
+	}
+
+	var ids []string
+	seen := make(map[string]struct{})
+	var walk func(node parse.Node)
+	walk = func(node parse.Node) {
+		switch n := node.(type) {
+		case *parse.ListNode:
+			if n == nil {
+				return
+			}
+			for _, item := range n.Nodes {
+				walk(item)
+			}
+		case *parse.ActionNode:
+			walk(n.Pipe)
+		case *parse.PipeNode:
+			if n == nil {
+				return
+			}
+			for _, cmd := range n.Cmds {
+				walk(cmd)
+			}
+		case *parse.CommandNode:
+			if len(n.Args) > 1 {
+				if fn, ok := n.Args[0].(*parse.IdentifierNode); ok && (fn.Ident == "child" || fn.Ident == "childIf") {
+					if id, ok := n.Args[1].(*parse.StringNode); ok {
+						if _, dup := seen[id.Text]; !dup {
+							seen[id.Text] = struct{}{}
+							ids = append(ids, id.Text)
+						}
+					}
+				}
+			}
+			for _, arg := range n.Args {
+				walk(arg)
+			}
+		case *parse.IfNode:
+			walk(n.Pipe)
+			walk(n.List)
+			walk(n.ElseList)
+		case *parse.WithNode:
+			walk(n.Pipe)
+			walk(n.List)
+			walk(n.ElseList)
+		case *parse.RangeNode:
+			walk(n.Pipe)
+			walk(n.List)
+			walk(n.ElseList)
+		case *parse.TemplateNode:
+			walk(n.Pipe)
+		}
+	}
+
+	for _, tree := range trees {
+		walk(tree.Root)
+	}
+
+	return ids
+}
@@ -0,0 +1,51 @@
+// Command go-partial lints, renders and inspects partial templates.
+//
+// Usage:
+//
+//	go-partial lint [flags] dir
+//	go-partial render [flags] [template ...]
+//	go-partial tree config
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+const usage = `usage: go-partial <command> [flags] [arguments]
+
+commands:
+  lint     parse the templates of a directory and report problems
+  render   render templates with fixture data and a simulated request
+  tree     print the partial structure declared in a config file
+
+run "go-partial <command> -h" for the flags of a command
+`
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// run executes the command and returns the exit code: 0 on success, 1 when problems were found and 2 on usage errors.
+func run(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return 2
+	}
+
+	switch args[0] {
+	case "lint":
+		return runLint(args[1:], stdout, stderr)
+	case "render":
+		return runRender(args[1:], stdout, stderr)
+	case "tree":
+		return runTree(args[1:], stdout, stderr)
+	case "help", "-h", "-help", "--help":
+		fmt.Fprint(stdout, usage)
+		return 0
+	default:
+		fmt.Fprintf(stderr, "go-partial: unknown command %q\n\n%s", args[0], usage)
+		return 2
+	}
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	for name, content := range files {
+		name = filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(name, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return dir
+}
+
+func TestCommands(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"templates/layout.html":  `<main>{{ child "content" }}{{ child "sidebar" }}</main>`,
+		"templates/content.html": `<h1>{{ upper .Data.Title }}</h1>{{ appLink "home" }}{{ selection }}`,
+		"templates/a.html":       `<p>tab a</p>`,
+		"templates/b.html":       `<p>tab b</p>`,
+		"templates/footer.html":  `<footer id="footer">{{ .Data.Title }}</footer>`,
+		"templates/broken.html":  `{{ if }}`,
+		"templates/other.html":   `{{ child "unknown" }}{{ missingFunc }}`,
+		"templates/typo.html":    `{{ child "footer" }}{{ child "foter" }}`,
+		"page.yaml": `id: root
+templates: [layout.html]
+children:
+  - id: content
+    templates: [content.html]
+    selection:
+      default: a
+      partials:
+        a: {id: tab-a, templates: [a.html]}
+        b: {id: tab-b, templates: [b.html]}
+oob:
+  - id: footer
+    templates: [footer.html]
+`,
+		"page.json":    `{"id": "root", "templates": ["content.html"], "oob": [{"id": "root"}]}`,
+		"fixture.json": `{"Title": "hello"}`,
+	})
+
+	templates := filepath.Join(dir, "templates")
+	config := filepath.Join(dir, "page.yaml")
+	fixture := filepath.Join(dir, "fixture.json")
+
+	tests := []struct {
+		name     string
+		args     []string
+		code     int
+		expected []string
+		stderr   string
+	}{
+		{
+			name: "tree",
+			args: []string{"tree", config},
+			expected: []string{
+				"root (layout.html)",
+				"├── content (content.html) [default: a]",
+				"│   ├── select a: tab-a (a.html)",
+				"│   └── select b: tab-b (b.html)",
+				"└── oob: footer (footer.html)",
+			},
+		},
+		{
+			name: "tree as dot",
+			args: []string{"tree", "-format", "dot", config},
+			expected: []string{
+				"digraph partials {",
+				`  node [shape=box, fontname="monospace"];`,
+				`  n0 [label="root\nlayout.html"];`,
+				`  n1 [label="content\ncontent.html"];`,
+				`  n2 [label="tab-a\na.html"];`,
+				`  n1 -> n2 [style=dotted, label="select a (default)"];`,
+				`  n3 [label="tab-b\nb.html"];`,
+				`  n1 -> n3 [style=dotted, label="select b"];`,
+				`  n0 -> n1;`,
+				`  n4 [label="footer\nfooter.html"];`,
+				`  n0 -> n4 [style=dashed, label="oob"];`,
+				"}",
+			},
+		},
+		{
+			name:   "tree with duplicate id",
+			args:   []string{"tree", filepath.Join(dir, "page.json")},
+			code:   2,
+			stderr: `/root/root: duplicate partial id "root"`,
+		},
+		{
+			name: "lint",
+			args: []string{"lint", "-config", config, "-funcs", "appLink", templates},
+			code: 1,
+			expected: []string{
+				`layout.html:1:36: child "sidebar" is not registered on partial 'root' (partial 'root')`,
+				`broken.html: template: broken.html:1: missing value for if (partial 'broken.html')`,
+				`other.html:1:9: child "unknown" is not registered on partial 'other.html' (partial 'other.html')`,
+				`other.html:1:24: function "missingFunc" is not defined (partial 'other.html')`,
+				`typo.html:1:29: child "foter" is not registered on partial 'typo.html' (partial 'typo.html')`,
+			},
+			stderr: "5 problem(s) found",
+		},
+		{
+			name: "lint without stubs",
+			args: []string{"lint", templates},
+			code: 1,
+			expected: []string{
+				`broken.html: template: broken.html:1: missing value for if (partial 'broken.html')`,
+				`content.html:1:35: function "appLink" is not defined (partial 'content.html')`,
+				`layout.html:1:36: child "sidebar" is not registered on partial 'layout.html' (partial 'layout.html')`,
+				`other.html:1:9: child "unknown" is not registered on partial 'other.html' (partial 'other.html')`,
+				`other.html:1:24: function "missingFunc" is not defined (partial 'other.html')`,
+				`typo.html:1:29: child "foter" is not registered on partial 'typo.html' (partial 'typo.html')`,
+			},
+		},
+		{
+			name:     "render",
+			args:     []string{"render", "-dir", templates, "-data", fixture, "footer.html"},
+			expected: []string{`<footer id="footer">hello</footer>`},
+		},
+		{
+			name:     "render config",
+			args:     []string{"render", "-dir", templates, "-config", config, "-data", fixture, "-funcs", "appLink"},
+			expected: []string{`<main><h1>HELLO</h1><p>tab a</p></main>`},
+		},
+		{
+			name: "render target",
+			args: []string{"render", "-dir", templates, "-config", config, "-data", fixture, "-funcs", "appLink",
+				"-connector", "htmx", "-target", "content", "-select", "b"},
+			expected: []string{`<h1>HELLO</h1><p>tab b</p><footer id="footer">hello</footer>`},
+		},
+		{
+			name:   "unknown connector",
+			args:   []string{"render", "-connector", "nope", "content.html"},
+			code:   2,
+			stderr: `unknown connector "nope"`,
+		},
+		{
+			name:   "unknown command",
+			args:   []string{"serve"},
+			code:   2,
+			stderr: `unknown command "serve"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+
+			code := run(tt.args, &stdout, &stderr)
+			if code != tt.code {
+				t.Fatalf("expected exit code %d, got %d\nstdout: %s\nstderr: %s", tt.code, code, stdout.String(), stderr.String())
+			}
+
+			if tt.expected != nil {
+				expected := strings.Join(tt.expected, "\n")
+				if got := strings.TrimSpace(stdout.String()); got != expected {
+					t.Errorf("expected output:\n%s\ngot:\n%s", expected, got)
+				}
+			}
+
+			if tt.stderr != "" && !strings.Contains(stderr.String(), tt.stderr) {
+				t.Errorf("expected stderr to contain %q, got %q", tt.stderr, stderr.String())
+			}
+		})
+	}
+}
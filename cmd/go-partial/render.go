@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/partial-coffee/go-partial"
+	"github.com/partial-coffee/go-partial/connector"
+)
+
+const renderUsage = `usage: go-partial render [flags] [template ...]
+
+Renders the templates, or the partial tree declared in the config, to stdout.
+The fixture data is available as .Data in every partial. The target, select and action
+flags simulate a partial request of the chosen connector.
+
+flags:
+`
+
+// connectors are the connectors available to the render command by name.
+var connectors = map[string]func(c *connector.Config) connector.Connector{
+	"partial":     connector.NewPartial,
+	"htmx":        connector.NewHTMX,
+	"turbo":       connector.NewTurbo,
+	"unpoly":      connector.NewUnpoly,
+	"alpine":      connector.NewAlpine,
+	"alpine-ajax": connector.NewAlpineAjax,
+	"stimulus":    connector.NewStimulus,
+	"vue":         connector.NewVue,
+}
+
+// requestHeaders are the headers a connector expects on a partial request besides the target header.
+var requestHeaders = map[string]map[string]string{
+	"htmx": {"HX-Request": "true"},
+}
+
+func runRender(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("render", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprint(stderr, renderUsage)
+		flags.PrintDefaults()
+	}
+
+	var funcs funcsFlag
+	dir := flags.String("dir", ".", "`directory` the template paths are relative to")
+	config := flags.String("config", "", "JSON or YAML `file` declaring the partial tree")
+	data := flags.String("data", "", "JSON or YAML `file` with the fixture data")
+	target := flags.String("target", "", "`id` of the requested partial")
+	selectKey := flags.String("select", "", "requested selection `key`")
+	action := flags.String("action", "", "requested `action`")
+	connectorName := flags.String("connector", "partial", "`name` of the connector: "+strings.Join(connectorNames(), ", "))
+	method := flags.String("method", http.MethodGet, "`method` of the simulated request")
+	url := flags.String("url", "/", "`url` of the simulated request")
+	flags.Var(&funcs, "funcs", "comma separated `names` of application functions to stub, can be repeated")
+
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	newConnector, ok := connectors[*connectorName]
+	if !ok {
+		fmt.Fprintf(stderr, "go-partial: unknown connector %q\n", *connectorName)
+		return 2
+	}
+	c := newConnector(nil)
+
+	var fixture map[string]any
+	if *data != "" {
+		if err := decodeFile(*data, &fixture); err != nil {
+			fmt.Fprintf(stderr, "go-partial: %v\n", err)
+			return 2
+		}
+	}
+
+	var root *partial.Partial
+	switch {
+	case *config != "" && flags.NArg() > 0:
+		fmt.Fprintln(stderr, "go-partial: pass either a config or templates, not both")
+		return 2
+	case *config != "":
+		def, err := loadDefinition(*config)
+		if err != nil {
+			fmt.Fprintf(stderr, "go-partial: %v\n", err)
+			return 2
+		}
+
+		// the data declared in the config takes precedence over the fixture
+		walkDefinition(def, func(d *partial.Definition) {
+			if d.Data == nil {
+				d.Data = make(map[string]any, len(fixture))
+			}
+			for k, v := range fixture {
+				if _, ok := d.Data[k]; !ok {
+					d.Data[k] = v
+				}
+			}
+		})
+
+		if root, err = def.Build(stubRegistry(def)); err != nil {
+			fmt.Fprintf(stderr, "go-partial: %v\n", err)
+			return 2
+		}
+	case flags.NArg() > 0:
+		root = partial.New(flags.Args()...).MergeData(fixture, true)
+	default:
+		flags.Usage()
+		return 2
+	}
+
+	r, err := http.NewRequest(*method, *url, nil)
+	if err != nil {
+		fmt.Fprintf(stderr, "go-partial: %v\n", err)
+		return 2
+	}
+	setHeader(r, c.GetTargetHeader(), *target)
+	setHeader(r, c.GetSelectHeader(), *selectKey)
+	setHeader(r, c.GetActionHeader(), *action)
+	if *target != "" {
+		for k, v := range requestHeaders[*connectorName] {
+			r.Header.Set(k, v)
+		}
+	}
+
+	svc := partial.NewService(&partial.Config{
+		Connector: c,
+		FuncMap:   funcs.funcMap(),
+		FS:        os.DirFS(*dir),
+	})
+
+	layout := svc.NewLayout()
+	layout.Set(root)
+
+	out, err := layout.RenderWithRequest(context.Background(), r)
+	if err != nil {
+		fmt.Fprintf(stderr, "go-partial: %v\n", err)
+		return 1
+	}
+
+	fmt.Fprintln(stdout, out)
+
+	return 0
+}
+
+func setHeader(r *http.Request, key, value string) {
+	if value != "" {
+		r.Header.Set(key, value)
+	}
+}
+
+func connectorNames() []string {
+	names := make([]string, 0, len(connectors))
+	for name := range connectors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/partial-coffee/go-partial"
+)
+
+const treeUsage = `usage: go-partial tree [flags] config
+
+Prints the partial structure declared in the JSON or YAML config file.
+
+flags:
+`
+
+func runTree(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("tree", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprint(stderr, treeUsage)
+		flags.PrintDefaults()
+	}
+
+	format := flags.String("format", "text", "output `format`: text, json, dot or html")
+
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+
+	def, err := loadDefinition(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintf(stderr, "go-partial: %v\n", err)
+		return 2
+	}
+
+	if *format == "text" {
+		printDefinition(stdout, def, "", "", "")
+		return 0
+	}
+
+	root, err := def.Build(stubRegistry(def))
+	if err != nil {
+		fmt.Fprintf(stderr, "go-partial: %v\n", err)
+		return 2
+	}
+
+	node := partial.Tree(root)
+	switch *format {
+	case "json":
+		err = node.WriteJSON(stdout)
+	case "dot":
+		err = node.WriteDOT(stdout)
+	case "html":
+		err = node.WriteHTML(stdout)
+	default:
+		fmt.Fprintf(stderr, "go-partial: unknown format %q\n", *format)
+		return 2
+	}
+
+	if err != nil {
+		fmt.Fprintf(stderr, "go-partial: %v\n", err)
+		return 1
+	}
+
+	return 0
+}
+
+// printDefinition prints the partial on one line followed by the partials below it, indented with box-drawing characters.
+func printDefinition(w io.Writer, d *partial.Definition, prefix, branch, label string) {
+	line := d.ID
+	if line == "" {
+		// selection partials default to their key as id
+		line = label[strings.LastIndex(label, " ")+1:]
+	}
+	if label != "" {
+		line = label + ": " + line
+	}
+	if len(d.Templates) > 0 {
+		line += " (" + strings.Join(d.Templates, ", ") + ")"
+	}
+	if d.Selection != nil && d.Selection.Default != "" {
+		line += " [default: " + d.Selection.Default + "]"
+	}
+	fmt.Fprintln(w, prefix+branch+line)
+
+	type entry struct {
+		def   *partial.Definition
+		label string
+	}
+
+	var entries []entry
+	for _, child := range d.Children {
+		entries = append(entries, entry{def: child})
+	}
+	for _, child := range d.OOB {
+		entries = append(entries, entry{def: child, label: "oob"})
+	}
+	if d.Selection != nil {
+		for _, key := range selectionKeys(d.Selection) {
+			entries = append(entries, entry{def: d.Selection.Partials[key], label: "select " + key})
+		}
+	}
+
+	switch branch {
+	case "├── ":
+		prefix += "│   "
+	case "└── ":
+		prefix += "    "
+	}
+
+	for i, e := range entries {
+		next := "├── "
+		if i == len(entries)-1 {
+			next = "└── "
+		}
+		printDefinition(w, e.def, prefix, next, e.label)
+	}
+}
@@ -0,0 +1,227 @@
+package partial
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"strings"
+)
+
+var (
+	// StateFieldPrefix is the prefix of the form field carrying the signed state of a component.
+	StateFieldPrefix = "_state_"
+
+	// ErrNoStateKey is returned when a component state is signed or verified without a configured key.
+	ErrNoStateKey = errors.New("no state signing key configured")
+	// ErrStateTampered is returned when the signature of a component state does not match.
+	ErrStateTampered = errors.New("state signature is invalid")
+	// ErrStateMalformed is returned when a component state cannot be decoded.
+	ErrStateMalformed = errors.New("state is malformed")
+)
+
+type (
+	// Component is a partial with a typed state that survives between requests.
+	// The state is serialized, signed and embedded in the rendered HTML with the componentState template function;
+	// when the component is rendered again the state is verified and decoded, and passed to the handler of the requested
+	// action when the request targets the component.
+	Component[S any] struct {
+		partial  *Partial
+		initial  S
+		handlers map[string]func(ctx context.Context, state *S, data *Data) error
+	}
+
+	// StateError is returned when the state of a component cannot be restored.
+	StateError struct {
+		ID  string
+		Err error
+	}
+)
+
+func (e *StateError) Error() string {
+	return fmt.Sprintf("invalid state for component '%s': %v", e.ID, e.Err)
+}
+
+func (e *StateError) Unwrap() error {
+	return e.Err
+}
+
+// StateFieldName returns the name of the form field carrying the state of the component with the given id.
+func StateFieldName(id string) string {
+	return StateFieldPrefix + id
+}
+
+// NewComponent turns the partial into a stateful component with the given initial state.
+// The state is available as .Data.State in the templates.
+func NewComponent[S any](p *Partial, initial S) *Component[S] {
+	c := &Component[S]{
+		partial:  p,
+		initial:  initial,
+		handlers: make(map[string]func(ctx context.Context, state *S, data *Data) error),
+	}
+
+	p.component = c.restore
+
+	return c
+}
+
+// On registers the handler of an action, it can modify the state before the component is rendered.
+func (c *Component[S]) On(action string, handler func(ctx context.Context, state *S, data *Data) error) *Component[S] {
+	c.handlers[action] = handler
+	return c
+}
+
+// Partial returns the partial of the component, to be added to the partial tree.
+func (c *Component[S]) Partial() *Partial {
+	return c.partial
+}
+
+// restore restores the state from the request, applies the requested action when the request targets the
+// component and stores the state for the templates.
+func (c *Component[S]) restore(ctx context.Context, p *Partial, data *Data) error {
+	state := c.initial
+
+	if data.Request != nil {
+		if token := data.Request.FormValue(StateFieldName(p.id)); token != "" {
+			if err := p.verifyState(token, &state); err != nil {
+				p.getLogger().Error("error restoring component state", "id", p.id, "error", err)
+				return err
+			}
+		}
+	}
+
+	if p.GetRequestedPartial() == p.id {
+		if handler, ok := c.handlers[p.GetRequestedAction()]; ok {
+			if err := handler(ctx, &state, data); err != nil {
+				return err
+			}
+		}
+	}
+
+	p.AddData("State", state)
+
+	return nil
+}
+
+// SetStateKeys sets the keys used to sign component states.
+// The first key signs new states, all keys are accepted when verifying, which allows rotating keys.
+func (svc *Service) SetStateKeys(keys ...[]byte) *Service {
+	svc.config.StateKeys = keys
+	return svc
+}
+
+// RotateStateKey makes the key the signing key, previous keys remain valid for verification.
+func (svc *Service) RotateStateKey(key []byte) *Service {
+	svc.config.StateKeys = append([][]byte{key}, svc.config.StateKeys...)
+	return svc
+}
+
+// signState serializes and signs the state for the partial.
+func (p *Partial) signState(state any) (string, error) {
+	keys := p.getStateKeys()
+	if len(keys) == 0 {
+		return "", &StateError{ID: p.id, Err: ErrNoStateKey}
+	}
+
+	b, err := json.Marshal(state)
+	if err != nil {
+		return "", &StateError{ID: p.id, Err: fmt.Errorf("%w: %v", ErrStateMalformed, err)}
+	}
+
+	payload := base64.RawURLEncoding.EncodeToString(b)
+	return payload + "." + base64.RawURLEncoding.EncodeToString(stateMAC(keys[0], p.id, payload)), nil
+}
+
+// verifyState verifies the signed state and decodes it into target.
+func (p *Partial) verifyState(token string, target any) error {
+	keys := p.getStateKeys()
+	if len(keys) == 0 {
+		return &StateError{ID: p.id, Err: ErrNoStateKey}
+	}
+
+	payload, signature, ok := strings.Cut(token, ".")
+	if !ok {
+		return &StateError{ID: p.id, Err: ErrStateMalformed}
+	}
+
+	mac, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil {
+		return &StateError{ID: p.id, Err: ErrStateMalformed}
+	}
+
+	valid := false
+	for _, key := range keys {
+		if hmac.Equal(mac, stateMAC(key, p.id, payload)) {
+			valid = true
+			break
+		}
+	}
+	if !valid {
+		return &StateError{ID: p.id, Err: ErrStateTampered}
+	}
+
+	b, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return &StateError{ID: p.id, Err: ErrStateMalformed}
+	}
+
+	if err = json.Unmarshal(b, target); err != nil {
+		return &StateError{ID: p.id, Err: fmt.Errorf("%w: %v", ErrStateMalformed, err)}
+	}
+
+	return nil
+}
+
+func (p *Partial) getStateKeys() [][]byte {
+	if p.stateKeys != nil {
+		return p.stateKeys
+	}
+
+	if p.parent != nil {
+		return p.parent.getStateKeys()
+	}
+
+	return nil
+}
+
+// stateMAC binds the signature to the component id, so the state of one component cannot be replayed on another.
+func stateMAC(key []byte, id, payload string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(id))
+	mac.Write([]byte{0})
+	mac.Write([]byte(payload))
+	return mac.Sum(nil)
+}
+
+func componentStateFunc(p *Partial) func() (template.HTML, error) {
+	return func() (template.HTML, error) {
+		value, err := componentStateValueFunc(p)()
+		if err != nil {
+			return "", err
+		}
+
+		return template.HTML(fmt.Sprintf(`<input type="hidden" name="%s" value="%s">`,
+			template.HTMLEscapeString(StateFieldName(p.id)), template.HTMLEscapeString(value))), nil
+	}
+}
+
+func componentStateValueFunc(p *Partial) func() (string, error) {
+	return func() (string, error) {
+		state, ok := p.data["State"]
+		if !ok {
+			return "", fmt.Errorf("partial '%s' has no component state", p.id)
+		}
+
+		value, err := p.signState(state)
+		if err != nil {
+			p.getLogger().Error("error signing component state", "id", p.id, "error", err)
+			return "", err
+		}
+
+		return value, nil
+	}
+}
@@ -0,0 +1,159 @@
+package partial
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/partial-coffee/go-partial/connector"
+)
+
+type counterState struct {
+	Count int
+}
+
+func TestComponent(t *testing.T) {
+	fsys := &InMemoryFS{
+		Files: map[string]string{
+			"templates/index.html":   `<main>{{ child "counter" }}</main>`,
+			"templates/counter.html": `<form id="counter">{{ componentState }}<span>{{ .Data.State.Count }}</span></form>`,
+		},
+	}
+
+	svc := NewService(&Config{FS: fsys, StateKeys: [][]byte{[]byte("secret")}})
+
+	render := func(form url.Values) (string, error) {
+		counter := NewComponent(New("templates/counter.html").ID("counter"), counterState{Count: 0}).
+			On("increment", func(ctx context.Context, state *counterState, data *Data) error {
+				state.Count++
+				return nil
+			})
+
+		p := New("templates/index.html").ID("root").With(counter.Partial())
+
+		request := httptest.NewRequest(http.MethodGet, "/", nil)
+		if form != nil {
+			request = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(form.Encode()))
+			request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			request.Header.Set("X-Target", "counter")
+			request.Header.Set("X-Action", "increment")
+		}
+
+		out, err := svc.NewLayout().Set(p).RenderWithRequest(context.Background(), request)
+		return string(out), err
+	}
+
+	stateRe := regexp.MustCompile(`name="_state_counter" value="([^"]+)"`)
+
+	out, err := render(nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(out, "<span>0</span>") {
+		t.Fatalf("expected initial state, got %s", out)
+	}
+
+	for _, expected := range []string{"<span>1</span>", "<span>2</span>"} {
+		match := stateRe.FindStringSubmatch(out)
+		if match == nil {
+			t.Fatalf("expected state field in %s", out)
+		}
+
+		out, err = render(url.Values{"_state_counter": {match[1]}})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !strings.Contains(out, expected) {
+			t.Errorf("expected %s, got %s", expected, out)
+		}
+	}
+
+	match := stateRe.FindStringSubmatch(out)
+	payload, signature, _ := strings.Cut(match[1], ".")
+	tampered := payload[:len(payload)-1] + "A." + signature
+
+	_, err = render(url.Values{"_state_counter": {tampered}})
+	var stateErr *StateError
+	if !errors.As(err, &stateErr) || !errors.Is(err, ErrStateTampered) || stateErr.ID != "counter" {
+		t.Errorf("expected tampered state error, got %v", err)
+	}
+}
+
+func TestComponentActionTarget(t *testing.T) {
+	fsys := &InMemoryFS{
+		Files: map[string]string{
+			"templates/index.html":   `{{ child "a" }}|{{ child "b" }}`,
+			"templates/counter.html": `{{ .Data.State.Count }}`,
+		},
+	}
+
+	// links without JavaScript carry the target and action in the query and render the full page
+	svc := NewService(&Config{
+		FS:        fsys,
+		Connector: connector.NewPartial(&connector.Config{UseURLQuery: true}),
+		StateKeys: [][]byte{[]byte("secret")},
+	})
+
+	counter := func(id string) *Partial {
+		return NewComponent(NewID(id, "templates/counter.html"), counterState{}).
+			On("inc", func(ctx context.Context, state *counterState, data *Data) error {
+				state.Count++
+				return nil
+			}).
+			Partial()
+	}
+
+	request := httptest.NewRequest(http.MethodPost, "/?target=a&action=inc", nil)
+	out, err := svc.NewLayout().Set(NewID("root", "templates/index.html").With(counter("a")).With(counter("b"))).RenderWithRequest(context.Background(), request)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if string(out) != "1|0" {
+		t.Errorf("expected only the targeted counter to change, got %s", out)
+	}
+}
+
+func TestComponentStateKeyRotation(t *testing.T) {
+	svc := NewService(&Config{StateKeys: [][]byte{[]byte("old")}})
+
+	p := New().ID("counter")
+	svc.NewLayout().Set(p)
+
+	token, err := p.signState(counterState{Count: 3})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	svc.RotateStateKey([]byte("new"))
+	svc.NewLayout().Set(p)
+
+	var state counterState
+	if err = p.verifyState(token, &state); err != nil || state.Count != 3 {
+		t.Errorf("expected state signed with the previous key to verify, got %v %+v", err, state)
+	}
+
+	// states are bound to the component id
+	other := New().ID("other")
+	svc.NewLayout().Set(other)
+	if err = other.verifyState(token, &state); !errors.Is(err, ErrStateTampered) {
+		t.Errorf("expected tampered state error, got %v", err)
+	}
+
+	svc.SetStateKeys([]byte("new"))
+	svc.NewLayout().Set(p)
+	if err = p.verifyState(token, &state); !errors.Is(err, ErrStateTampered) {
+		t.Errorf("expected tampered state error after removing the key, got %v", err)
+	}
+
+	svc.SetStateKeys()
+	svc.NewLayout().Set(p)
+	if _, err = p.signState(state); !errors.Is(err, ErrNoStateKey) {
+		t.Errorf("expected no key error, got %v", err)
+	}
+}
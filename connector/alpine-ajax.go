@@ -20,3 +20,26 @@ func NewAlpineAjax(c *Config) Connector {
 func (a *AlpineAjax) RenderPartial(r *http.Request) bool {
 	return r.Header.Get(a.targetHeader) != ""
 }
+
+// OOBSwapAttr marks the fragment with x-sync, so alpine-ajax merges it into the element with the same id on the page.
+// The strategy is set with x-merge, deletes and target selectors fall back to x-swap-oob.
+func (a *AlpineAjax) OOBSwapAttr(strategy, target string) string {
+	if target != "" {
+		return a.base.OOBSwapAttr(strategy, target)
+	}
+
+	switch strategy {
+	case "", SwapOuterHTML:
+		return "x-sync"
+	case SwapInnerHTML:
+		return `x-sync x-merge="update"`
+	case SwapBeforeEnd:
+		return `x-sync x-merge="append"`
+	case SwapAfterBegin:
+		return `x-sync x-merge="prepend"`
+	case SwapMorph:
+		return `x-sync x-merge="morph"`
+	}
+
+	return a.base.OOBSwapAttr(strategy, target)
+}
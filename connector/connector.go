@@ -23,6 +23,7 @@ type (
 		targetHeader string
 		selectHeader string
 		actionHeader string
+		oobAttr      string
 	}
 )
 
@@ -19,6 +19,7 @@ func NewHTMX(c *Config) Connector {
 			targetHeader: "HX-Target",
 			selectHeader: "X-Select",
 			actionHeader: "X-Action",
+			oobAttr:      "hx-swap-oob",
 		},
 		requestHeader:               "HX-Request",
 		boostedHeader:               "HX-Boosted",
@@ -0,0 +1,71 @@
+package connector
+
+import (
+	"fmt"
+	"html/template"
+)
+
+// Swap strategies for out-of-band fragments.
+const (
+	SwapOuterHTML  = "outerHTML"
+	SwapInnerHTML  = "innerHTML"
+	SwapBeforeEnd  = "beforeend"
+	SwapAfterBegin = "afterbegin"
+	SwapDelete     = "delete"
+	SwapMorph      = "morph"
+)
+
+// OOBRenderer is implemented by connectors that know how out-of-band fragments are expressed in their dialect.
+// HTMX uses hx-swap-oob, Turbo and Stimulus use turbo-stream elements, Unpoly uses up-hungry and alpine-ajax x-sync.
+// What a dialect cannot express, and every fragment of Alpine and Vue, which have no out-of-band dialect,
+// falls back to the x-swap-oob attribute, which needs a client-side handler.
+type OOBRenderer interface {
+	// OOBSwapAttr returns the attribute that marks the root element of a fragment as out-of-band.
+	OOBSwapAttr(strategy, target string) string
+	// RenderOOB returns the fragment as it should be sent to the client.
+	// For the delete strategy html is empty and the connector renders the complete instruction.
+	RenderOOB(id, strategy, target, html string) string
+	// WrapOOB wraps the fragment in an out-of-band element, so the fragment itself is swapped into the target.
+	WrapOOB(id, strategy, target, html string) string
+}
+
+func (x *base) OOBSwapAttr(strategy, target string) string {
+	if strategy == "" {
+		strategy = SwapOuterHTML
+	}
+
+	value := strategy
+	if target != "" {
+		value += ":" + target
+	}
+
+	return fmt.Sprintf(`%s="%s"`, x.getOOBAttr(), template.HTMLEscapeString(value))
+}
+
+func (x *base) RenderOOB(id, strategy, target, html string) string {
+	if strategy != SwapDelete {
+		return html
+	}
+
+	if target != "" {
+		return fmt.Sprintf(`<div %s></div>`, x.OOBSwapAttr(strategy, target))
+	}
+
+	return fmt.Sprintf(`<div id="%s" %s></div>`, template.HTMLEscapeString(id), x.OOBSwapAttr(strategy, ""))
+}
+
+func (x *base) WrapOOB(id, strategy, target, html string) string {
+	if target == "" {
+		target = "#" + id
+	}
+
+	return fmt.Sprintf(`<template %s>%s</template>`, x.OOBSwapAttr(strategy, target), html)
+}
+
+func (x *base) getOOBAttr() string {
+	if x.oobAttr == "" {
+		return "x-swap-oob"
+	}
+
+	return x.oobAttr
+}
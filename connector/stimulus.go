@@ -20,3 +20,18 @@ func NewStimulus(c *Config) Connector {
 func (s *Stimulus) RenderPartial(r *http.Request) bool {
 	return r.Header.Get(s.targetHeader) != ""
 }
+
+// OOBSwapAttr returns an empty attribute, Stimulus apps receive out-of-band fragments as Turbo streams.
+func (s *Stimulus) OOBSwapAttr(strategy, target string) string {
+	return ""
+}
+
+// RenderOOB wraps the fragment in a turbo-stream element.
+func (s *Stimulus) RenderOOB(id, strategy, target, html string) string {
+	return turboStream(id, strategy, target, html)
+}
+
+// WrapOOB wraps the fragment in a turbo-stream element.
+func (s *Stimulus) WrapOOB(id, strategy, target, html string) string {
+	return turboStream(id, strategy, target, html)
+}
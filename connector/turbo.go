@@ -1,5 +1,10 @@
 package connector
 
+import (
+	"fmt"
+	"html/template"
+)
+
 type Turbo struct {
 	base
 }
@@ -14,3 +19,48 @@ func NewTurbo(c *Config) Connector {
 		},
 	}
 }
+
+// OOBSwapAttr returns an empty attribute, Turbo wraps out-of-band fragments in a turbo-stream element instead.
+func (t *Turbo) OOBSwapAttr(strategy, target string) string {
+	return ""
+}
+
+// RenderOOB wraps the fragment in a turbo-stream element.
+func (t *Turbo) RenderOOB(id, strategy, target, html string) string {
+	return turboStream(id, strategy, target, html)
+}
+
+// WrapOOB wraps the fragment in a turbo-stream element.
+func (t *Turbo) WrapOOB(id, strategy, target, html string) string {
+	return turboStream(id, strategy, target, html)
+}
+
+// turboStream returns the fragment as a turbo-stream element, the strategy picks the action.
+func turboStream(id, strategy, target, html string) string {
+	var action, method string
+	switch strategy {
+	case SwapInnerHTML:
+		action = "update"
+	case SwapBeforeEnd:
+		action = "append"
+	case SwapAfterBegin:
+		action = "prepend"
+	case SwapDelete:
+		action = "remove"
+	case SwapMorph:
+		action, method = "replace", ` method="morph"`
+	default:
+		action = "replace"
+	}
+
+	targetAttr := fmt.Sprintf(`target="%s"`, template.HTMLEscapeString(id))
+	if target != "" {
+		targetAttr = fmt.Sprintf(`targets="%s"`, template.HTMLEscapeString(target))
+	}
+
+	if strategy == SwapDelete {
+		return fmt.Sprintf(`<turbo-stream action="%s" %s></turbo-stream>`, action, targetAttr)
+	}
+
+	return fmt.Sprintf(`<turbo-stream action="%s"%s %s><template>%s</template></turbo-stream>`, action, method, targetAttr, html)
+}
@@ -20,3 +20,13 @@ func NewUnpoly(c *Config) Connector {
 func (u *Unpoly) RenderPartial(r *http.Request) bool {
 	return r.Header.Get(u.targetHeader) != ""
 }
+
+// OOBSwapAttr marks the fragment as up-hungry, so Unpoly replaces the element with the same id on the page.
+// Hungry elements can only be replaced, other strategies and target selectors fall back to x-swap-oob.
+func (u *Unpoly) OOBSwapAttr(strategy, target string) string {
+	if target == "" && (strategy == "" || strategy == SwapOuterHTML || strategy == SwapMorph) {
+		return "up-hungry"
+	}
+
+	return u.base.OOBSwapAttr(strategy, target)
+}
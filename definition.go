@@ -0,0 +1,290 @@
+package partial
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	// ErrUnknownAction is returned when a definition references an action that is not registered.
+	ErrUnknownAction = errors.New("unknown action")
+	// ErrUnknownGuard is returned when a definition references a guard that is not registered.
+	ErrUnknownGuard = errors.New("unknown guard")
+	// ErrInvalidDefinition is returned when a definition is incomplete or inconsistent.
+	ErrInvalidDefinition = errors.New("invalid definition")
+)
+
+type (
+	// Definition declares a partial and the partials below it, it is loaded from a JSON or YAML file.
+	Definition struct {
+		ID             string         `json:"id" yaml:"id"`
+		Templates      []string       `json:"templates,omitempty" yaml:"templates,omitempty"`
+		BasePath       string         `json:"basePath,omitempty" yaml:"basePath,omitempty"`
+		Data           map[string]any `json:"data,omitempty" yaml:"data,omitempty"`
+		Action         string         `json:"action,omitempty" yaml:"action,omitempty"`
+		TemplateAction string         `json:"templateAction,omitempty" yaml:"templateAction,omitempty"`
+		// Guards run in order before every render of the partial, the first error stops the render
+		Guards   []string      `json:"guards,omitempty" yaml:"guards,omitempty"`
+		Children []*Definition `json:"children,omitempty" yaml:"children,omitempty"`
+		OOB      []*Definition `json:"oob,omitempty" yaml:"oob,omitempty"`
+		// Swap and Target configure how an OOB child is swapped, see OOBSwap and OOBTarget
+		Swap      string               `json:"swap,omitempty" yaml:"swap,omitempty"`
+		Target    string               `json:"target,omitempty" yaml:"target,omitempty"`
+		Selection *SelectionDefinition `json:"selection,omitempty" yaml:"selection,omitempty"`
+		// Fixtures are the examples of the partial shown in the Gallery
+		Fixtures []Fixture `json:"fixtures,omitempty" yaml:"fixtures,omitempty"`
+	}
+
+	// SelectionDefinition declares the selection map of a partial, the id of a selection partial defaults to its key.
+	SelectionDefinition struct {
+		Default  string                 `json:"default,omitempty" yaml:"default,omitempty"`
+		Partials map[string]*Definition `json:"partials" yaml:"partials"`
+	}
+
+	// GuardFunc decides whether the partial may be rendered, returning an error stops the render.
+	GuardFunc func(ctx context.Context, p *Partial, data *Data) error
+
+	// Registry holds the actions and guards a Definition refers to by name.
+	Registry struct {
+		actions map[string]func(ctx context.Context, p *Partial, data *Data) (*Partial, error)
+		guards  map[string]GuardFunc
+	}
+)
+
+// NewRegistry returns an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{
+		actions: make(map[string]func(ctx context.Context, p *Partial, data *Data) (*Partial, error)),
+		guards:  make(map[string]GuardFunc),
+	}
+}
+
+// Action registers an action under the name, it can be used as action and as template action.
+func (reg *Registry) Action(name string, action func(ctx context.Context, p *Partial, data *Data) (*Partial, error)) *Registry {
+	reg.actions[name] = action
+	return reg
+}
+
+// Guard registers a guard under the name.
+func (reg *Registry) Guard(name string, guard GuardFunc) *Registry {
+	reg.guards[name] = guard
+	return reg
+}
+
+// Load reads the definition file from the filesystem and builds the partial tree.
+func Load(fsys fs.FS, name string, reg *Registry) (*Partial, error) {
+	def, err := LoadDefinition(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := def.Build(reg)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+
+	return p, nil
+}
+
+// LoadDefinition reads a definition file, the format is chosen by the extension: .json, .yaml or .yml.
+// Unknown keys are rejected, so typos do not go unnoticed.
+func LoadDefinition(fsys fs.FS, name string) (*Definition, error) {
+	b, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return nil, fmt.Errorf("error reading definition: %w", err)
+	}
+
+	var def Definition
+	switch strings.ToLower(path.Ext(name)) {
+	case ".json":
+		dec := json.NewDecoder(bytes.NewReader(b))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(&def)
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(bytes.NewReader(b))
+		dec.KnownFields(true)
+		err = dec.Decode(&def)
+	default:
+		return nil, fmt.Errorf("%s: unsupported definition format, use .json, .yaml or .yml", name)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("%s: error decoding definition: %w", name, err)
+	}
+
+	return &def, nil
+}
+
+// Build creates the partial tree of the definition, resolving actions and guards against the registry.
+// The errors name the location of the partial in the tree, e.g. "root/content: unknown action \"load\"".
+func (d *Definition) Build(reg *Registry) (*Partial, error) {
+	if reg == nil {
+		reg = NewRegistry()
+	}
+
+	return d.build(reg, "", "")
+}
+
+func (d *Definition) build(reg *Registry, parent, fallbackID string) (*Partial, error) {
+	id := d.ID
+	if id == "" {
+		id = fallbackID
+	}
+	if id == "" {
+		return nil, fmt.Errorf("%s/: %w: partial has no id", parent, ErrInvalidDefinition)
+	}
+	location := parent + "/" + id
+	if parent == "" {
+		location = id
+	}
+
+	p := NewID(id, d.Templates...)
+
+	if d.BasePath != "" {
+		p.SetBasePath(d.BasePath)
+	}
+
+	for k, v := range d.Data {
+		p.AddData(k, v)
+	}
+
+	for _, f := range d.Fixtures {
+		if f.Name == "" {
+			return nil, fmt.Errorf("%s: %w: fixture has no name", location, ErrInvalidDefinition)
+		}
+		p.WithFixture(f.Name, f.Data, f.Headers)
+	}
+
+	if err := d.buildActions(reg, p, location); err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]struct{})
+	for _, child := range d.Children {
+		c, err := d.buildChild(reg, child, location, seen)
+		if err != nil {
+			return nil, err
+		}
+		p.With(c)
+	}
+
+	for _, child := range d.OOB {
+		c, err := d.buildChild(reg, child, location, seen)
+		if err != nil {
+			return nil, err
+		}
+
+		var opts []OOBOption
+		if child.Swap != "" {
+			opts = append(opts, OOBSwap(child.Swap))
+		}
+		if child.Target != "" {
+			opts = append(opts, OOBTarget(child.Target))
+		}
+		p.WithOOB(c, opts...)
+	}
+
+	if d.Selection != nil {
+		keys := make([]string, 0, len(d.Selection.Partials))
+		for key := range d.Selection.Partials {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		if _, ok := d.Selection.Partials[d.Selection.Default]; d.Selection.Default != "" && !ok {
+			return nil, fmt.Errorf("%s: %w: default selection %q is not declared", location, ErrInvalidDefinition, d.Selection.Default)
+		}
+
+		partials := make(map[string]*Partial, len(keys))
+		for _, key := range keys {
+			selected := d.Selection.Partials[key]
+			if selected == nil {
+				return nil, fmt.Errorf("%s: %w: selection %q is empty", location, ErrInvalidDefinition, key)
+			}
+
+			s, err := selected.build(reg, location, key)
+			if err != nil {
+				return nil, err
+			}
+			partials[key] = s
+		}
+		p.WithSelectMap(d.Selection.Default, partials)
+	}
+
+	return p, nil
+}
+
+func (d *Definition) buildChild(reg *Registry, child *Definition, location string, seen map[string]struct{}) (*Partial, error) {
+	if child == nil {
+		return nil, fmt.Errorf("%s: %w: child is empty", location, ErrInvalidDefinition)
+	}
+
+	if _, ok := seen[child.ID]; ok {
+		return nil, fmt.Errorf("%s: %w: duplicate child id %q", location, ErrInvalidDefinition, child.ID)
+	}
+	seen[child.ID] = struct{}{}
+
+	return child.build(reg, location, "")
+}
+
+// buildActions resolves the action, template action and guards of the partial.
+func (d *Definition) buildActions(reg *Registry, p *Partial, location string) error {
+	var action func(ctx context.Context, p *Partial, data *Data) (*Partial, error)
+	if d.Action != "" {
+		var ok bool
+		if action, ok = reg.actions[d.Action]; !ok {
+			return fmt.Errorf("%s: %w %q", location, ErrUnknownAction, d.Action)
+		}
+	}
+
+	if d.TemplateAction != "" {
+		templateAction, ok := reg.actions[d.TemplateAction]
+		if !ok {
+			return fmt.Errorf("%s: %w %q", location, ErrUnknownAction, d.TemplateAction)
+		}
+		p.WithTemplateAction(templateAction)
+	}
+
+	guards := make([]GuardFunc, 0, len(d.Guards))
+	for _, name := range d.Guards {
+		guard, ok := reg.guards[name]
+		if !ok {
+			return fmt.Errorf("%s: %w %q", location, ErrUnknownGuard, name)
+		}
+		guards = append(guards, guard)
+	}
+
+	// guards run on every render of the partial, not only when the partial is the target of the request
+	for _, guard := range guards {
+		p.BeforeRender(guardHook(guard))
+	}
+
+	if action != nil {
+		p.WithAction(action)
+	}
+
+	return nil
+}
+
+// guardHook runs the guard before the partial is rendered. A failed guard fails the render of the whole
+// request, a child is otherwise rendered in place with its error.
+func guardHook(guard GuardFunc) BeforeRenderHook {
+	return func(ctx context.Context, p *Partial, data *Data) error {
+		err := guard(ctx, p, data)
+		if err != nil {
+			if state := getRenderState(ctx); state != nil {
+				state.fail(err)
+			}
+		}
+		return err
+	}
+}
@@ -0,0 +1,214 @@
+package partial
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/partial-coffee/go-partial/connector"
+)
+
+func TestLoadDefinition(t *testing.T) {
+	fsys := &InMemoryFS{
+		Files: map[string]string{
+			"templates/index.html":   `<main>{{ child "content" }}</main>`,
+			"templates/content.html": `<h1>{{ .Data.Title }} {{ .Data.User }}</h1>{{ selection }}`,
+			"templates/tab1.html":    `<p>tab 1 {{ .BasePath }}</p>`,
+			"templates/tab2.html":    `<p>tab 2</p>`,
+			"templates/footer.html":  `<footer {{ oobSwapIfEnabled "true" }} id="footer">footer</footer>`,
+			"pages/index.yaml": `id: root
+templates: [templates/index.html]
+children:
+  - id: content
+    templates: [templates/content.html]
+    data:
+      Title: Welcome
+    action: loadUser
+    guards: [loggedIn]
+    selection:
+      default: tab1
+      partials:
+        tab1:
+          templates: [templates/tab1.html]
+          basePath: /tabs
+        tab2:
+          templates: [templates/tab2.html]
+oob:
+  - id: footer
+    templates: [templates/footer.html]
+    swap: innerHTML
+`,
+			"pages/index.json": `{
+  "id": "root",
+  "templates": ["templates/index.html"],
+  "children": [{
+    "id": "content",
+    "templates": ["templates/content.html"],
+    "data": {"Title": "Welcome"},
+    "action": "loadUser",
+    "guards": ["loggedIn"],
+    "selection": {
+      "default": "tab1",
+      "partials": {
+        "tab1": {"templates": ["templates/tab1.html"], "basePath": "/tabs"},
+        "tab2": {"templates": ["templates/tab2.html"]}
+      }
+    }
+  }],
+  "oob": [{"id": "footer", "templates": ["templates/footer.html"], "swap": "innerHTML"}]
+}`,
+			"pages/typo.yaml":    "id: root\ntemplate: [templates/index.html]\n",
+			"pages/unknown.json": `{"id": "root", "children": [{"id": "content", "action": "save"}]}`,
+			"pages/guard.json":   `{"id": "root", "children": [{"id": "content", "guards": ["admin"]}]}`,
+			"pages/default.json": `{"id": "root", "selection": {"default": "c", "partials": {"a": {}, "b": {}}}}`,
+			"pages/dup.json":     `{"id": "root", "children": [{"id": "a"}], "oob": [{"id": "a"}]}`,
+			"pages/noid.json":    `{"id": "root", "children": [{"templates": ["templates/tab1.html"]}]}`,
+			"pages/fixture.json": `{"id": "root", "fixtures": [{"data": {"Title": "x"}}]}`,
+			"pages/fixtures.yaml": `id: root
+templates: [templates/content.html]
+fixtures:
+  - name: empty
+  - name: admin
+    data:
+      Title: Admin
+    headers:
+      Authorization: token
+`,
+		},
+	}
+
+	loadUser := func(ctx context.Context, p *Partial, data *Data) (*Partial, error) {
+		data.Data["User"] = "alice"
+		return p, nil
+	}
+
+	loggedIn := func(ctx context.Context, p *Partial, data *Data) error {
+		if data.Request.Header.Get("Authorization") == "" {
+			return errors.New("not logged in")
+		}
+		return nil
+	}
+
+	registry := NewRegistry().Action("loadUser", loadUser).Guard("loggedIn", loggedIn)
+
+	// the same tree built in Go
+	expected := func() *Partial {
+		content := NewID("content", "templates/content.html").SetData(map[string]any{"Title": "Welcome"})
+		content.BeforeRender(loggedIn).WithAction(loadUser)
+		content.WithSelectMap("tab1", map[string]*Partial{
+			"tab1": NewID("tab1", "templates/tab1.html").SetBasePath("/tabs"),
+			"tab2": NewID("tab2", "templates/tab2.html"),
+		})
+
+		return NewID("root", "templates/index.html").
+			With(content).
+			WithOOB(NewID("footer", "templates/footer.html"), OOBSwap(connector.SwapInnerHTML))
+	}
+
+	render := func(t *testing.T, p *Partial, headers map[string]string) (string, error) {
+		t.Helper()
+
+		r, _ := http.NewRequest(http.MethodGet, "/", nil)
+		for k, v := range headers {
+			r.Header.Set(k, v)
+		}
+
+		out, err := NewService(&Config{FS: fsys}).NewLayout().Set(p).RenderWithRequest(context.Background(), r)
+		return string(out), err
+	}
+
+	for _, name := range []string{"pages/index.yaml", "pages/index.json"} {
+		t.Run(name, func(t *testing.T) {
+			p, err := Load(fsys, name, registry)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if !reflect.DeepEqual(Tree(p), Tree(expected())) {
+				t.Errorf("tree differs from the tree built in Go")
+			}
+
+			for _, headers := range []map[string]string{
+				{"Authorization": "token"},
+				{"Authorization": "token", "X-Target": "content", "X-Select": "tab2"},
+			} {
+				got, err := render(t, p, headers)
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+
+				want, _ := render(t, expected(), headers)
+				if got != want {
+					t.Errorf("expected %q, got %q", want, got)
+				}
+			}
+
+			// the guard also runs when the guarded partial is rendered as a child of the full page
+			for _, headers := range []map[string]string{{"X-Target": "content"}, nil} {
+				if out, err := render(t, p, headers); err == nil || !strings.Contains(err.Error(), "not logged in") || strings.Contains(out, "Welcome") {
+					t.Errorf("expected guard error, got %v and %q", err, out)
+				}
+			}
+		})
+	}
+
+	t.Run("rendered", func(t *testing.T) {
+		p, _ := Load(fsys, "pages/index.yaml", registry)
+		got, _ := render(t, p, map[string]string{"Authorization": "token", "X-Target": "content"})
+
+		expected := `<h1>Welcome alice</h1><p>tab 1 /tabs</p><footer x-swap-oob="innerHTML" id="footer">footer</footer>`
+		if got != expected {
+			t.Errorf("expected %q, got %q", expected, got)
+		}
+	})
+
+	t.Run("fixtures", func(t *testing.T) {
+		p, err := Load(fsys, "pages/fixtures.yaml", registry)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		expected := []Fixture{
+			{Name: "empty"},
+			{Name: "admin", Data: map[string]any{"Title": "Admin"}, Headers: map[string]string{"Authorization": "token"}},
+		}
+		if !reflect.DeepEqual(p.Fixtures(), expected) {
+			t.Errorf("expected %v, got %v", expected, p.Fixtures())
+		}
+	})
+
+	errorTests := []struct {
+		name     string
+		file     string
+		err      error
+		expected string
+	}{
+		{name: "unknown key", file: "pages/typo.yaml", expected: "field template not found"},
+		{name: "unknown action", file: "pages/unknown.json", err: ErrUnknownAction, expected: `pages/unknown.json: root/content: unknown action "save"`},
+		{name: "unknown guard", file: "pages/guard.json", err: ErrUnknownGuard, expected: `pages/guard.json: root/content: unknown guard "admin"`},
+		{name: "unknown default", file: "pages/default.json", err: ErrInvalidDefinition, expected: `default selection "c" is not declared`},
+		{name: "duplicate id", file: "pages/dup.json", err: ErrInvalidDefinition, expected: `root: invalid definition: duplicate child id "a"`},
+		{name: "missing id", file: "pages/noid.json", err: ErrInvalidDefinition, expected: `root/: invalid definition: partial has no id`},
+		{name: "fixture without name", file: "pages/fixture.json", err: ErrInvalidDefinition, expected: `root: invalid definition: fixture has no name`},
+		{name: "missing file", file: "pages/missing.json", err: fs.ErrNotExist, expected: "error reading definition"},
+	}
+
+	for _, tt := range errorTests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Load(fsys, tt.file, registry)
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if tt.err != nil && !errors.Is(err, tt.err) {
+				t.Errorf("expected error %v, got %v", tt.err, err)
+			}
+			if !strings.Contains(err.Error(), tt.expected) {
+				t.Errorf("expected error to contain %q, got %q", tt.expected, err.Error())
+			}
+		})
+	}
+}
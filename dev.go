@@ -0,0 +1,203 @@
+package partial
+
+import (
+	"fmt"
+	"html/template"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Annotation selects how dev mode marks the output of every partial.
+type Annotation int
+
+const (
+	// AnnotateComments wraps the output in HTML comments carrying the partial details.
+	AnnotateComments Annotation = iota
+	// AnnotateAttributes adds data-partial-* attributes to the first element of the output,
+	// output that does not start with an element falls back to comments.
+	AnnotateAttributes
+)
+
+// devOptions holds the dev mode settings of a partial, nil means inherited from the parent.
+type devOptions struct {
+	annotation Annotation
+}
+
+// SetDevMode enables dev mode, which annotates the output of every partial with its id, templates,
+// render duration and data keys. Use the devOverlay template function to inspect the annotations in the browser.
+func (svc *Service) SetDevMode(enabled bool, annotation Annotation) *Service {
+	svc.config.DevMode = enabled
+	svc.config.DevAnnotation = annotation
+	return svc
+}
+
+func (p *Partial) getDevOptions() *devOptions {
+	if p.dev != nil {
+		return p.dev
+	}
+
+	if p.parent != nil {
+		return p.parent.getDevOptions()
+	}
+
+	return nil
+}
+
+// annotate marks the rendered output with the details of the partial when dev mode is enabled.
+func (p *Partial) annotate(html template.HTML, duration time.Duration) template.HTML {
+	dev := p.getDevOptions()
+	if dev == nil {
+		return html
+	}
+
+	keys := make([]string, 0, len(p.data))
+	for k := range p.data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	details := [][2]string{
+		{"id", p.id},
+		{"template", strings.Join(p.templates, ",")},
+		{"duration", duration.Round(time.Microsecond).String()},
+		{"data", strings.Join(keys, ",")},
+	}
+
+	if dev.annotation == AnnotateAttributes {
+		var attrs strings.Builder
+		for _, d := range details {
+			fmt.Fprintf(&attrs, ` data-partial-%s="%s"`, d[0], template.HTMLEscapeString(d[1]))
+		}
+		if out, ok := injectAttributes(string(html), attrs.String()); ok {
+			return template.HTML(out)
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString("<!-- partial")
+	for _, d := range details {
+		fmt.Fprintf(&b, " %s=%q", d[0], commentSafe(d[1]))
+	}
+	b.WriteString(" -->")
+	b.WriteString(string(html))
+	fmt.Fprintf(&b, "<!-- /partial id=%q -->", commentSafe(p.id))
+
+	return template.HTML(b.String())
+}
+
+// injectAttributes adds the attributes to the first element of the output, attrs starts with a space.
+func injectAttributes(html string, attrs string) (string, bool) {
+	i := 0
+	for i < len(html) {
+		start := strings.IndexByte(html[i:], '<')
+		if start < 0 || strings.TrimSpace(html[i:i+start]) != "" {
+			return "", false
+		}
+		i += start
+
+		// skip doctype and comments
+		if strings.HasPrefix(html[i:], "<!") {
+			end := strings.IndexByte(html[i:], '>')
+			if strings.HasPrefix(html[i:], "<!--") {
+				if end = strings.Index(html[i:], "-->"); end >= 0 {
+					end += 2
+				}
+			}
+			if end < 0 {
+				return "", false
+			}
+			i += end + 1
+			continue
+		}
+
+		if i+1 >= len(html) || !isASCIILetter(html[i+1]) {
+			return "", false
+		}
+
+		nameEnd := i + 1
+		for nameEnd < len(html) && !strings.ContainsRune(" \t\r\n/>", rune(html[nameEnd])) {
+			nameEnd++
+		}
+
+		return html[:nameEnd] + attrs + html[nameEnd:], true
+	}
+
+	return "", false
+}
+
+func isASCIILetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+// commentSafe keeps a value from closing the comment it is written in.
+func commentSafe(s string) string {
+	return strings.ReplaceAll(s, "--", "- -")
+}
+
+// devOverlayFunc returns the overlay script in dev mode and nothing otherwise, place it at the end of the body.
+// Holding Alt while hovering the page highlights the element under the pointer and shows its partial.
+func devOverlayFunc(p *Partial) func() template.HTML {
+	return func() template.HTML {
+		if p.getDevOptions() == nil {
+			return ""
+		}
+		return devOverlayScript
+	}
+}
+
+// devOverlayScript highlights the element under the pointer and shows the partial that produced it,
+// found through the data-partial-* attributes or the enclosing annotation comments.
+const devOverlayScript template.HTML = `<script>
+(function () {
+  if (window.__partialOverlay) return;
+  window.__partialOverlay = true;
+
+  var box = document.createElement('div');
+  box.style.cssText = 'position:fixed;pointer-events:none;outline:2px solid #e8590c;background:rgba(232,89,12,.08);z-index:2147483646;display:none';
+  var label = document.createElement('div');
+  label.style.cssText = 'position:fixed;pointer-events:none;font:12px/1.4 monospace;color:#fff;background:#e8590c;padding:2px 6px;border-radius:3px;z-index:2147483647;display:none;max-width:60vw;white-space:pre-wrap';
+
+  function parseComment(text) {
+    var info = {}, re = /(\w+)="((?:[^"\\]|\\.)*)"/g, m;
+    while ((m = re.exec(text))) info[m[1]] = m[2];
+    return info;
+  }
+
+  function find(el) {
+    for (var n = el; n && n !== document; n = n.parentNode) {
+      if (n.dataset && n.dataset.partialId) {
+        return { el: n, info: { id: n.dataset.partialId, template: n.dataset.partialTemplate, duration: n.dataset.partialDuration, data: n.dataset.partialData } };
+      }
+      var depth = 0;
+      for (var s = n.previousSibling; s; s = s.previousSibling) {
+        if (s.nodeType !== 8) continue;
+        var t = s.nodeValue.trim();
+        if (t.indexOf('/partial ') === 0) depth++;
+        else if (t.indexOf('partial ') === 0) {
+          if (depth === 0) return { el: n, info: parseComment(t) };
+          depth--;
+        }
+      }
+    }
+    return null;
+  }
+
+  document.addEventListener('mouseover', function (e) {
+    if (!e.altKey) { box.style.display = label.style.display = 'none'; return; }
+    var found = find(e.target);
+    if (!found) return;
+    var r = found.el.getBoundingClientRect();
+    box.style.cssText += ';display:block;left:' + r.left + 'px;top:' + r.top + 'px;width:' + r.width + 'px;height:' + r.height + 'px';
+    label.textContent = found.info.id + '  ' + (found.info.template || '') + '  ' + (found.info.duration || '') + (found.info.data ? '\ndata: ' + found.info.data : '');
+    label.style.cssText += ';display:block;left:' + Math.max(r.left, 0) + 'px;top:' + Math.max(r.top - 22, 0) + 'px';
+  });
+
+  document.addEventListener('keyup', function (e) {
+    if (e.key === 'Alt') box.style.display = label.style.display = 'none';
+  });
+
+  document.body.appendChild(box);
+  document.body.appendChild(label);
+})();
+</script>`
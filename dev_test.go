@@ -0,0 +1,91 @@
+package partial
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestDevMode(t *testing.T) {
+	fsys := &InMemoryFS{
+		Files: map[string]string{
+			"templates/index.html":   `<!DOCTYPE html><html><body>{{ child "content" }}{{ devOverlay }}</body></html>`,
+			"templates/content.html": `<div class="content">{{ .Data.Title }}</div>`,
+			"templates/text.html":    `plain -- text`,
+		},
+	}
+
+	duration := regexp.MustCompile(`duration="[^"]+"`) // durations vary between runs
+
+	build := func() *Partial {
+		content := NewID("content", "templates/content.html").SetData(map[string]any{"Title": "Hello", "Author": "me"})
+		return NewID("root", "templates/index.html").With(content)
+	}
+
+	tests := []struct {
+		name       string
+		annotation Annotation
+		partial    func() *Partial
+		expected   string
+	}{
+		{
+			name:       "comments",
+			annotation: AnnotateComments,
+			partial:    build,
+			expected: `<!-- partial id="root" template="templates/index.html" duration="" data="" -->` +
+				`<!DOCTYPE html><html><body>` +
+				`<!-- partial id="content" template="templates/content.html" duration="" data="Author,Title" -->` +
+				`<div class="content">Hello</div>` +
+				`<!-- /partial id="content" -->`,
+		},
+		{
+			name:       "attributes",
+			annotation: AnnotateAttributes,
+			partial:    build,
+			expected: `<!DOCTYPE html><html data-partial-id="root" data-partial-template="templates/index.html" data-partial-duration="" data-partial-data=""><body>` +
+				`<div data-partial-id="content" data-partial-template="templates/content.html" data-partial-duration="" data-partial-data="Author,Title" class="content">Hello</div>`,
+		},
+		{
+			name:       "attributes fall back to comments",
+			annotation: AnnotateAttributes,
+			partial: func() *Partial {
+				return NewID("text--id", "templates/text.html")
+			},
+			expected: `<!-- partial id="text- -id" template="templates/text.html" duration="" data="" -->plain -- text<!-- /partial id="text- -id" -->`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := NewService(&Config{FS: fsys}).SetDevMode(true, tt.annotation)
+
+			r, _ := http.NewRequest(http.MethodGet, "/", nil)
+			out, err := svc.NewLayout().Set(tt.partial()).RenderWithRequest(context.Background(), r)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			got := duration.ReplaceAllString(string(out), `duration=""`)
+			if !strings.HasPrefix(got, tt.expected) {
+				t.Errorf("expected output to start with\n%s\ngot\n%s", tt.expected, got)
+			}
+		})
+	}
+
+	t.Run("overlay", func(t *testing.T) {
+		r, _ := http.NewRequest(http.MethodGet, "/", nil)
+
+		out, _ := NewService(&Config{FS: fsys}).SetDevMode(true, AnnotateComments).NewLayout().Set(build()).RenderWithRequest(context.Background(), r)
+		if !strings.Contains(string(out), "window.__partialOverlay") {
+			t.Errorf("expected the overlay script in dev mode")
+		}
+
+		out, _ = NewService(&Config{FS: fsys}).NewLayout().Set(build()).RenderWithRequest(context.Background(), r)
+		expected := `<!DOCTYPE html><html><body><div class="content">Hello</div></body></html>`
+		if string(out) != expected {
+			t.Errorf("expected %q without dev mode, got %q", expected, out)
+		}
+	})
+}
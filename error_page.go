@@ -0,0 +1,191 @@
+package partial
+
+import (
+	"errors"
+	"html/template"
+	"io/fs"
+	"net/http"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// excerptLines is the number of lines shown before and after the failing line on the error page.
+const excerptLines = 5
+
+// templatePosition matches the position in the errors of html/template, e.g. "template: index.html:3:14: ...".
+var templatePosition = regexp.MustCompile(`template: ([^:\s]+):(\d+)(?::(\d+))?:`)
+
+type (
+	// RenderError is returned when a partial fails to render, it locates the failure in the partial tree and template.
+	RenderError struct {
+		// Chain contains the ids from the root to the failing partial
+		Chain []string
+		// Template is the template file, Line and Column the position in it, when the error names one
+		Template string
+		Line     int
+		Column   int
+		// DataKeys are the keys of the partial data at the time of the failure
+		DataKeys []string
+		Err      error
+
+		fs fs.FS
+	}
+
+	// errorPageLine is a line of the template excerpt shown on the error page.
+	errorPageLine struct {
+		Number  int
+		Text    string
+		Failing bool
+	}
+
+	// errorPageHeader is a connector header of the request shown on the error page.
+	errorPageHeader struct {
+		Name  string
+		Value string
+	}
+)
+
+func (e *RenderError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *RenderError) Unwrap() error {
+	return e.Err
+}
+
+// renderError wraps the error with the location of the failure, errors of nested partials keep their location.
+func (p *Partial) renderError(err error) error {
+	var renderErr *RenderError
+	if errors.As(err, &renderErr) {
+		return err
+	}
+
+	renderErr = &RenderError{Err: err, fs: p.getFS()}
+
+	for current := p; current != nil; current = current.parent {
+		renderErr.Chain = append([]string{current.id}, renderErr.Chain...)
+	}
+
+	for k := range p.data {
+		renderErr.DataKeys = append(renderErr.DataKeys, k)
+	}
+	sort.Strings(renderErr.DataKeys)
+
+	if m := templatePosition.FindStringSubmatch(err.Error()); m != nil {
+		renderErr.Template = m[1]
+		for _, name := range p.templates {
+			if path.Base(name) == m[1] {
+				renderErr.Template = name
+				break
+			}
+		}
+		renderErr.Line, _ = strconv.Atoi(m[2])
+		renderErr.Column, _ = strconv.Atoi(m[3])
+	}
+
+	return renderErr
+}
+
+// excerpt returns the lines around the failing line of the template.
+func (e *RenderError) excerpt() []errorPageLine {
+	if e.Template == "" || e.Line == 0 || e.fs == nil {
+		return nil
+	}
+
+	b, err := fs.ReadFile(e.fs, e.Template)
+	if err != nil {
+		return nil
+	}
+
+	lines := strings.Split(string(b), "\n")
+	if e.Line > len(lines) {
+		return nil
+	}
+
+	from := max(e.Line-excerptLines, 1)
+	to := min(e.Line+excerptLines, len(lines))
+
+	out := make([]errorPageLine, 0, to-from+1)
+	for i := from; i <= to; i++ {
+		out = append(out, errorPageLine{Number: i, Text: lines[i-1], Failing: i == e.Line})
+	}
+
+	return out
+}
+
+// writeError writes the error response: the error page in dev mode and a generic error otherwise.
+func (p *Partial) writeError(w http.ResponseWriter, r *http.Request, err error) {
+	if p.getDevOptions() == nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	var renderErr *RenderError
+	if !errors.As(err, &renderErr) {
+		renderErr = p.renderError(err).(*RenderError)
+	}
+
+	var headers []errorPageHeader
+	if c := p.getConnector(); c != nil && r != nil {
+		for _, name := range []string{c.GetTargetHeader(), c.GetSelectHeader(), c.GetActionHeader()} {
+			headers = append(headers, errorPageHeader{Name: name, Value: r.Header.Get(name)})
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusInternalServerError)
+
+	if err = errorPageTemplate.Execute(w, map[string]any{
+		"Error":   renderErr,
+		"Excerpt": renderErr.excerpt(),
+		"Headers": headers,
+		"Request": r,
+	}); err != nil {
+		p.getLogger().Error("error writing error page", "error", err)
+	}
+}
+
+var errorPageTemplate = template.Must(template.New("error").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Render error</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+h1 { color: #c92a2a; font-size: 1.4em; }
+pre, code { font-family: monospace; }
+.message { background: #fff5f5; border-left: 4px solid #c92a2a; padding: 1em; white-space: pre-wrap; }
+.chain span + span::before { content: " › "; color: #999; }
+table { border-collapse: collapse; }
+td, th { text-align: left; padding: .2em 1em .2em 0; vertical-align: top; }
+.excerpt { background: #f8f9fa; padding: .5em 0; overflow-x: auto; }
+.excerpt div { padding: 0 1em; white-space: pre; }
+.excerpt .failing { background: #ffe3e3; font-weight: bold; }
+.excerpt .number { display: inline-block; width: 3em; color: #999; user-select: none; }
+</style>
+</head>
+<body>
+<h1>Error rendering partial</h1>
+<div class="message">{{ .Error.Error }}</div>
+
+<h2>Partials</h2>
+<p class="chain">{{ range .Error.Chain }}<span>{{ . }}</span>{{ end }}</p>
+
+{{ if .Error.Template }}<h2>{{ .Error.Template }}{{ if .Error.Line }}:{{ .Error.Line }}{{ if .Error.Column }}:{{ .Error.Column }}{{ end }}{{ end }}</h2>
+{{ with .Excerpt }}<pre class="excerpt">{{ range . }}<div{{ if .Failing }} class="failing"{{ end }}><span class="number">{{ .Number }}</span>{{ .Text }}</div>{{ end }}</pre>{{ end }}
+{{ end }}
+<h2>Data keys</h2>
+<p>{{ range $i, $k := .Error.DataKeys }}{{ if $i }}, {{ end }}<code>{{ $k }}</code>{{ else }}none{{ end }}</p>
+
+{{ with .Request }}<h2>Request</h2>
+<table>
+<tr><th>Method</th><td>{{ .Method }}</td></tr>
+<tr><th>URL</th><td>{{ .URL }}</td></tr>
+{{ range $.Headers }}<tr><th>{{ .Name }}</th><td>{{ .Value }}</td></tr>
+{{ end }}</table>{{ end }}
+</body>
+</html>
+`))
@@ -0,0 +1,77 @@
+package partial
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/partial-coffee/go-partial/connector"
+)
+
+func TestErrorPage(t *testing.T) {
+	fsys := &InMemoryFS{
+		Files: map[string]string{
+			"templates/index.html": `<html><body>{{ child "content" }}</body></html>`,
+			"templates/content.html": "<ul>\n" +
+				"  <li>{{ .Data.Title }}</li>\n" +
+				"  <li>{{ index .Data.Items 5 }}</li>\n" +
+				"</ul>",
+		},
+	}
+
+	handle := func(svc *Service) (*httptest.ResponseRecorder, error) {
+		content := NewID("content", "templates/content.html").SetData(map[string]any{"Title": "<b>title</b>", "Items": []string{"a"}})
+		p := NewID("root", "templates/index.html").With(content)
+
+		r := httptest.NewRequest(http.MethodGet, "/items", nil)
+		r.Header.Set("X-Target", "content")
+		w := httptest.NewRecorder()
+
+		return w, svc.NewLayout().Set(p).WriteWithRequest(context.Background(), w, r)
+	}
+
+	t.Run("dev mode", func(t *testing.T) {
+		w, err := handle(NewService(&Config{FS: fsys, Connector: connector.NewPartial(nil)}).SetDevMode(true, AnnotateComments))
+
+		var renderErr *RenderError
+		if !errors.As(err, &renderErr) {
+			t.Fatalf("expected a render error, got %v", err)
+		}
+
+		if strings.Join(renderErr.Chain, "/") != "root/content" || renderErr.Template != "templates/content.html" || renderErr.Line != 3 {
+			t.Errorf("unexpected location: %v %s:%d", renderErr.Chain, renderErr.Template, renderErr.Line)
+		}
+
+		if w.Code != http.StatusInternalServerError {
+			t.Errorf("expected status %d, got %d", http.StatusInternalServerError, w.Code)
+		}
+
+		for _, s := range []string{
+			`<p class="chain"><span>root</span><span>content</span></p>`,
+			`<h2>templates/content.html:3:9</h2>`,
+			`<div><span class="number">2</span>  &lt;li&gt;{{ .Data.Title }}&lt;/li&gt;</div>`,
+			`<div class="failing"><span class="number">3</span>  &lt;li&gt;{{ index .Data.Items 5 }}&lt;/li&gt;</div>`,
+			`<code>Items</code>, <code>Title</code>`,
+			`<tr><th>X-Target</th><td>content</td></tr>`,
+			`index out of range: 5`,
+		} {
+			if !strings.Contains(w.Body.String(), s) {
+				t.Errorf("expected error page to contain %q, got:\n%s", s, w.Body.String())
+			}
+		}
+	})
+
+	t.Run("production", func(t *testing.T) {
+		w, err := handle(NewService(&Config{FS: fsys, Connector: connector.NewPartial(nil)}))
+		if err == nil {
+			t.Fatal("expected an error")
+		}
+
+		if w.Code != http.StatusInternalServerError || w.Body.String() != "Internal Server Error\n" {
+			t.Errorf("expected a generic error response, got %d %q", w.Code, w.Body.String())
+		}
+	})
+}
@@ -0,0 +1,339 @@
+package partial
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"sort"
+
+	"github.com/partial-coffee/go-partial/connector"
+)
+
+// ErrUnknownFixture is returned when a gallery case names a component, partial or fixture that is not registered.
+var ErrUnknownFixture = errors.New("unknown fixture")
+
+type (
+	// Fixture is a named example of a partial: data merged into the partial and headers added to the simulated request.
+	Fixture struct {
+		Name    string            `json:"name" yaml:"name"`
+		Data    map[string]any    `json:"data,omitempty" yaml:"data,omitempty"`
+		Headers map[string]string `json:"headers,omitempty" yaml:"headers,omitempty"`
+	}
+
+	// Gallery lists the registered components and renders their fixtures, on their own or inside their layout.
+	// It is an in-app component browser, so do not mount it in production.
+	Gallery struct {
+		service    *Service
+		wrapper    func() *Partial
+		components []galleryComponent
+	}
+
+	// GalleryCase is a fixture of a partial of a registered component, it can be rendered with Gallery.Render.
+	GalleryCase struct {
+		Component string
+		Partial   string
+		Fixture   string
+	}
+
+	// GalleryRequest holds the playground values of a render, empty values are left out of the request.
+	GalleryRequest struct {
+		Layout bool
+		Target string
+		Select string
+		Action string
+	}
+
+	galleryComponent struct {
+		name  string
+		build func() *Partial
+	}
+
+	// galleryLink is a fixture in the navigation of the gallery.
+	galleryLink struct {
+		GalleryCase
+		URL     string
+		Current bool
+	}
+)
+
+// WithFixture attaches a named fixture to the partial, a fixture with the same name is replaced.
+func (p *Partial) WithFixture(name string, data map[string]any, headers map[string]string) *Partial {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	fixture := Fixture{Name: name, Data: data, Headers: headers}
+	for i, f := range p.fixtures {
+		if f.Name == name {
+			p.fixtures[i] = fixture
+			return p
+		}
+	}
+	p.fixtures = append(p.fixtures, fixture)
+
+	return p
+}
+
+// Fixtures returns the fixtures attached to the partial.
+func (p *Partial) Fixtures() []Fixture {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	return append([]Fixture{}, p.fixtures...)
+}
+
+// NewGallery returns a gallery rendering the components with the configuration of the service.
+func NewGallery(svc *Service) *Gallery {
+	return &Gallery{service: svc}
+}
+
+// Wrap sets the wrapper used to render a fixture inside its layout.
+func (g *Gallery) Wrap(wrapper func() *Partial) *Gallery {
+	g.wrapper = wrapper
+	return g
+}
+
+// Register adds a component, build returns a new tree of partials on every call.
+// Every partial of the tree that has fixtures is listed in the gallery.
+func (g *Gallery) Register(name string, build func() *Partial) *Gallery {
+	g.components = append(g.components, galleryComponent{name: name, build: build})
+	return g
+}
+
+// Cases returns every fixture of every registered component, e.g. to render them as golden tests.
+func (g *Gallery) Cases() []GalleryCase {
+	var cases []GalleryCase
+	for _, c := range g.components {
+		walkPartials(c.build(), func(p *Partial) {
+			for _, f := range p.Fixtures() {
+				cases = append(cases, GalleryCase{Component: c.name, Partial: p.id, Fixture: f.Name})
+			}
+		})
+	}
+
+	return cases
+}
+
+// Render renders the fixture of the case. Without Layout only the partial is rendered,
+// with Layout the whole component is rendered inside the wrapper of the gallery.
+func (g *Gallery) Render(ctx context.Context, c GalleryCase, req GalleryRequest) (template.HTML, error) {
+	r, err := http.NewRequestWithContext(ctx, http.MethodGet, "/", nil)
+	if err != nil {
+		return "", err
+	}
+
+	return g.render(ctx, c, req, r)
+}
+
+func (g *Gallery) render(ctx context.Context, c GalleryCase, req GalleryRequest, r *http.Request) (template.HTML, error) {
+	root, p, fixture, err := g.find(c)
+	if err != nil {
+		return "", err
+	}
+
+	p.MergeData(fixture.Data, true)
+
+	r = r.Clone(ctx)
+	r.Header = make(http.Header)
+	for k, v := range fixture.Headers {
+		r.Header.Set(k, v)
+	}
+
+	conn := g.service.connector
+	if conn == nil {
+		conn = connector.NewPartial(nil)
+	}
+	for header, value := range map[string]string{
+		conn.GetTargetHeader(): req.Target,
+		conn.GetSelectHeader(): req.Select,
+		conn.GetActionHeader(): req.Action,
+	} {
+		if value != "" {
+			r.Header.Set(header, value)
+		}
+	}
+	// htmx only renders a part of the page for requests it marks as its own
+	if _, ok := conn.(*connector.HTMX); ok && req.Target != "" {
+		r.Header.Set("HX-Request", "true")
+	}
+
+	layout := g.service.NewLayout()
+	if !req.Layout {
+		layout.Set(p)
+		return p.RenderWithRequest(ctx, r)
+	}
+
+	layout.Set(root)
+	if g.wrapper != nil {
+		layout.Wrap(g.wrapper())
+	}
+
+	return layout.RenderWithRequest(ctx, r)
+}
+
+// find builds the component of the case and returns its root, the partial and the fixture.
+func (g *Gallery) find(c GalleryCase) (root *Partial, p *Partial, fixture Fixture, err error) {
+	for _, component := range g.components {
+		if component.name != c.Component {
+			continue
+		}
+
+		root = component.build()
+		walkPartials(root, func(candidate *Partial) {
+			if p == nil && candidate.id == c.Partial {
+				p = candidate
+			}
+		})
+		if p == nil {
+			return nil, nil, Fixture{}, fmt.Errorf("%w: partial %q of component %q", ErrUnknownFixture, c.Partial, c.Component)
+		}
+
+		for _, f := range p.Fixtures() {
+			if f.Name == c.Fixture {
+				return root, p, f, nil
+			}
+		}
+
+		return nil, nil, Fixture{}, fmt.Errorf("%w: fixture %q of partial %q", ErrUnknownFixture, c.Fixture, c.Partial)
+	}
+
+	return nil, nil, Fixture{}, fmt.Errorf("%w: component %q", ErrUnknownFixture, c.Component)
+}
+
+// walkPartials calls fn for the partial, its children and its selection partials, depth first.
+func walkPartials(p *Partial, fn func(p *Partial)) {
+	if p == nil {
+		return
+	}
+
+	fn(p)
+
+	for _, child := range p.getChildren() {
+		walkPartials(child, fn)
+	}
+
+	if p.selection != nil {
+		keys := make([]string, 0, len(p.selection.Partials))
+		for k := range p.selection.Partials {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			selected := p.selection.Partials[k]
+			if selected != nil && selected.parent == nil {
+				selected.parent = p
+			}
+			walkPartials(selected, fn)
+		}
+	}
+}
+
+// ServeHTTP serves the gallery: the index without query parameters, the playground of a fixture with
+// component, partial and fixture, and the bare output of the fixture with render=1.
+func (g *Gallery) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	c := GalleryCase{Component: q.Get("component"), Partial: q.Get("partial"), Fixture: q.Get("fixture")}
+	req := GalleryRequest{
+		Layout: q.Get("layout") != "",
+		Target: q.Get("target"),
+		Select: q.Get("select"),
+		Action: q.Get("action"),
+	}
+
+	var links []galleryLink
+	for _, gc := range g.Cases() {
+		links = append(links, galleryLink{
+			GalleryCase: gc,
+			URL:         "?" + url.Values{"component": {gc.Component}, "partial": {gc.Partial}, "fixture": {gc.Fixture}}.Encode(),
+			Current:     gc == c,
+		})
+	}
+
+	var err error
+	switch {
+	case c.Component == "":
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		err = galleryTemplate.Execute(w, map[string]any{"Links": links})
+	case q.Get("render") != "":
+		var out template.HTML
+		if out, err = g.render(r.Context(), c, req, r); err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, ErrUnknownFixture) {
+				status = http.StatusNotFound
+			}
+			http.Error(w, err.Error(), status)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_, err = w.Write([]byte(out))
+	default:
+		if _, _, _, err = g.find(c); err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+
+		render := url.Values{}
+		for k, v := range q {
+			render[k] = v
+		}
+		render.Set("render", "1")
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		err = galleryTemplate.Execute(w, map[string]any{
+			"Links":   links,
+			"Case":    c,
+			"Request": req,
+			"Frame":   "?" + render.Encode(),
+		})
+	}
+
+	if err != nil {
+		g.service.log(r.Context()).Error("error writing gallery", "error", err)
+	}
+}
+
+var galleryTemplate = template.Must(template.New("gallery").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Gallery{{ with .Case }} - {{ .Partial }} / {{ .Fixture }}{{ end }}</title>
+<style>
+body { font-family: sans-serif; margin: 0; display: flex; min-height: 100vh; color: #222; }
+nav { width: 18em; background: #f8f9fa; padding: 1em; border-right: 1px solid #dee2e6; }
+nav h2 { font-size: .9em; color: #666; margin: 1em 0 .3em; }
+nav a { display: block; padding: .15em 0; color: #1864ab; text-decoration: none; }
+nav a.current { font-weight: bold; }
+main { flex: 1; padding: 1em; display: flex; flex-direction: column; }
+form { display: flex; gap: .5em; align-items: center; margin-bottom: 1em; }
+iframe { flex: 1; border: 1px solid #dee2e6; width: 100%; min-height: 70vh; }
+</style>
+</head>
+<body>
+<nav>
+<h1>Gallery</h1>
+{{ $component := "" }}
+{{ range .Links }}{{ if ne .Component $component }}{{ $component = .Component }}<h2>{{ .Component }}</h2>{{ end }}
+<a href="{{ .URL }}"{{ if .Current }} class="current"{{ end }}>{{ .Partial }} / {{ .Fixture }}</a>
+{{ else }}<p>No fixtures registered.</p>{{ end }}
+</nav>
+<main>
+{{ with .Case }}
+<form method="get">
+<input type="hidden" name="component" value="{{ .Component }}">
+<input type="hidden" name="partial" value="{{ .Partial }}">
+<input type="hidden" name="fixture" value="{{ .Fixture }}">
+<label>target <input name="target" value="{{ $.Request.Target }}"></label>
+<label>select <input name="select" value="{{ $.Request.Select }}"></label>
+<label>action <input name="action" value="{{ $.Request.Action }}"></label>
+<label><input type="checkbox" name="layout" value="1"{{ if $.Request.Layout }} checked{{ end }}> layout</label>
+<button type="submit">Render</button>
+</form>
+<iframe src="{{ $.Frame }}"></iframe>
+{{ else }}<p>Select a fixture.</p>{{ end }}
+</main>
+</body>
+</html>
+`))
@@ -0,0 +1,140 @@
+package partial
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/partial-coffee/go-partial/connector"
+)
+
+func TestGallery(t *testing.T) {
+	fsys := &InMemoryFS{
+		Files: map[string]string{
+			"templates/wrapper.html": `<html>{{ child "content" }}</html>`,
+			"templates/index.html":   `<main>{{ child "card" }}</main>`,
+			"templates/card.html":    `<div class="card">{{ .Data.Title }}{{ if requestActionValue }} ({{ requestActionValue }}){{ end }}</div>`,
+			"templates/help.html":    `<aside>{{ .Data.Text }}</aside>`,
+		},
+	}
+
+	svc := NewService(&Config{FS: fsys, Connector: connector.NewHTMX(nil)})
+	gallery := NewGallery(svc).
+		Wrap(func() *Partial { return NewID("wrapper", "templates/wrapper.html") }).
+		Register("dashboard", func() *Partial {
+			card := NewID("card", "templates/card.html").
+				WithFixture("empty", map[string]any{"Title": "Nothing yet"}, nil).
+				WithFixture("full", map[string]any{"Title": "Sales"}, map[string]string{"X-Action": "refresh"})
+			return NewID("content", "templates/index.html").With(card)
+		}).
+		Register("help", func() *Partial {
+			return NewID("help", "templates/help.html").WithFixture("default", map[string]any{"Text": "Help"}, nil)
+		})
+
+	t.Run("cases", func(t *testing.T) {
+		expected := []GalleryCase{
+			{Component: "dashboard", Partial: "card", Fixture: "empty"},
+			{Component: "dashboard", Partial: "card", Fixture: "full"},
+			{Component: "help", Partial: "help", Fixture: "default"},
+		}
+
+		cases := gallery.Cases()
+		if len(cases) != len(expected) {
+			t.Fatalf("expected %v, got %v", expected, cases)
+		}
+		for i := range cases {
+			if cases[i] != expected[i] {
+				t.Errorf("expected %v, got %v", expected[i], cases[i])
+			}
+		}
+	})
+
+	tests := []struct {
+		name     string
+		c        GalleryCase
+		req      GalleryRequest
+		expected string
+	}{
+		{
+			name:     "on its own",
+			c:        GalleryCase{Component: "dashboard", Partial: "card", Fixture: "empty"},
+			expected: `<div class="card">Nothing yet</div>`,
+		},
+		{
+			name:     "fixture headers",
+			c:        GalleryCase{Component: "dashboard", Partial: "card", Fixture: "full"},
+			expected: `<div class="card">Sales (refresh)</div>`,
+		},
+		{
+			name:     "playground action",
+			c:        GalleryCase{Component: "dashboard", Partial: "card", Fixture: "empty"},
+			req:      GalleryRequest{Action: "reload"},
+			expected: `<div class="card">Nothing yet (reload)</div>`,
+		},
+		{
+			name:     "inside its layout",
+			c:        GalleryCase{Component: "dashboard", Partial: "card", Fixture: "empty"},
+			req:      GalleryRequest{Layout: true},
+			expected: `<html><main><div class="card">Nothing yet</div></main></html>`,
+		},
+		{
+			name:     "playground target",
+			c:        GalleryCase{Component: "dashboard", Partial: "card", Fixture: "empty"},
+			req:      GalleryRequest{Layout: true, Target: "card"},
+			expected: `<div class="card">Nothing yet</div>`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := gallery.Render(context.Background(), tt.c, tt.req)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if string(out) != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, out)
+			}
+		})
+	}
+
+	t.Run("unknown fixture", func(t *testing.T) {
+		_, err := gallery.Render(context.Background(), GalleryCase{Component: "help", Partial: "help", Fixture: "missing"}, GalleryRequest{})
+		if !errors.Is(err, ErrUnknownFixture) {
+			t.Errorf("expected ErrUnknownFixture, got %v", err)
+		}
+	})
+
+	t.Run("handler", func(t *testing.T) {
+		serve := func(target string) *httptest.ResponseRecorder {
+			rec := httptest.NewRecorder()
+			gallery.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+			return rec
+		}
+
+		index := serve("/gallery")
+		if !strings.Contains(index.Body.String(), `<a href="?component=dashboard&amp;fixture=full&amp;partial=card">card / full</a>`) {
+			t.Errorf("expected the index to link the fixtures, got %s", index.Body.String())
+		}
+
+		playground := serve("/gallery?component=dashboard&partial=card&fixture=full&action=save")
+		body := playground.Body.String()
+		if !strings.Contains(body, `class="current">card / full</a>`) || !strings.Contains(body, `name="action" value="save"`) {
+			t.Errorf("expected the playground of the fixture, got %s", body)
+		}
+		if !strings.Contains(body, `<iframe src="?action=save&amp;component=dashboard&amp;fixture=full&amp;partial=card&amp;render=1">`) {
+			t.Errorf("expected the frame to render the fixture, got %s", body)
+		}
+
+		rendered := serve("/gallery?action=save&component=dashboard&fixture=full&partial=card&render=1")
+		if rendered.Body.String() != `<div class="card">Sales (save)</div>` {
+			t.Errorf("unexpected render %q", rendered.Body.String())
+		}
+
+		if missing := serve("/gallery?component=nope&partial=x&fixture=y"); missing.Code != http.StatusNotFound {
+			t.Errorf("expected 404, got %d", missing.Code)
+		}
+	})
+}
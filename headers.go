@@ -0,0 +1,77 @@
+package partial
+
+import (
+	"net/http"
+	"time"
+)
+
+// Response is the status code and the headers set while rendering a request, see Partial.RenderResponse.
+type Response struct {
+	// Status is the status code of the response, 0 when none was set
+	Status int
+	Header http.Header
+}
+
+// ResponseHeader returns the headers of the current response. They are shared by every partial rendered
+// for the request, children, OOB and selection partials alike, so values can be added from any of them.
+func (d *Data) ResponseHeader() http.Header {
+	if d == nil {
+		return make(http.Header)
+	}
+
+	state := getRenderState(d.Ctx)
+	if state == nil {
+		return make(http.Header)
+	}
+
+	return state.header
+}
+
+// SetCookie adds a Set-Cookie header to the current response, invalid cookies are dropped.
+func (d *Data) SetCookie(cookie *http.Cookie) {
+	if v := cookie.String(); v != "" {
+		d.ResponseHeader().Add("Set-Cookie", v)
+	}
+}
+
+// DeleteCookie adds a Set-Cookie header that expires the cookie with the name and the root path.
+func (d *Data) DeleteCookie(name string) {
+	d.SetCookie(&http.Cookie{Name: name, Path: "/", MaxAge: -1, Expires: time.Unix(0, 0)})
+}
+
+// response returns the status code and the headers of the response. The headers of rendered partials, see
+// Partial.SetResponseHeaders, are included unless the request set the same header.
+func (s *renderState) response() *Response {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	res := &Response{Status: s.status, Header: s.header.Clone()}
+	if res.Status == 0 {
+		res.Status = s.partialStatus
+	}
+
+	for k, v := range s.partialHeader {
+		if _, ok := res.Header[k]; !ok {
+			res.Header[k] = append([]string{}, v...)
+		}
+	}
+
+	return res
+}
+
+// write copies the response to w. The headers replace the values of w, except for Set-Cookie,
+// which is added to the cookies set before, e.g. by a session middleware.
+func (res *Response) write(w http.ResponseWriter) {
+	for k, v := range res.Header {
+		if k != "Set-Cookie" {
+			w.Header().Del(k)
+		}
+		for _, value := range v {
+			w.Header().Add(k, value)
+		}
+	}
+
+	if res.Status != 0 {
+		w.WriteHeader(res.Status)
+	}
+}
@@ -0,0 +1,173 @@
+package partial
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestResponseHeader(t *testing.T) {
+	fsys := &InMemoryFS{
+		Files: map[string]string{
+			"templates/index.html":   `{{ child "content" }}{{ child "menu" }}`,
+			"templates/content.html": `<div>content</div>`,
+			"templates/menu.html":    `<nav>{{ selection }}</nav>`,
+			"templates/item.html":    `item`,
+			"templates/wrapper.html": `<main>{{ child "child" }}</main>`,
+			"templates/toast.html":   `<div id="toast">saved</div>`,
+		},
+	}
+
+	build := func() *Partial {
+		content := NewID("content", "templates/content.html").WithAction(func(ctx context.Context, p *Partial, data *Data) (*Partial, error) {
+			data.ResponseHeader().Add("Vary", "Accept")
+			data.SetCookie(&http.Cookie{Name: "session", Value: "abc", Path: "/"})
+
+			// OOB partials are rendered from a clone without the action, a hook still runs
+			toast := NewID("toast", "templates/toast.html").BeforeRender(func(ctx context.Context, p *Partial, data *Data) error {
+				data.ResponseHeader().Add("Vary", "X-Target")
+				data.DeleteCookie("flash")
+				return nil
+			})
+			data.AddOOB(toast, "")
+			return p, nil
+		})
+
+		item := NewID("item", "templates/item.html").WithAction(func(ctx context.Context, p *Partial, data *Data) (*Partial, error) {
+			p.SetResponseHeaders(map[string]string{"X-Selected": "item"})
+			return p, nil
+		})
+		menu := NewID("menu", "templates/menu.html").WithSelectMap("item", map[string]*Partial{"item": item})
+
+		return NewID("root", "templates/index.html").
+			SetResponseHeaders(map[string]string{"Cache-Control": "no-store"}).
+			With(content).
+			With(menu)
+	}
+
+	tests := []struct {
+		name     string
+		target   string
+		expected http.Header
+	}{
+		{
+			name: "full page",
+			expected: http.Header{
+				"Cache-Control": {"no-store"},
+				"Set-Cookie":    {"middleware=1"},
+				"X-Selected":    {"item"},
+			},
+		},
+		{
+			name:   "partial with oob",
+			target: "content",
+			expected: http.Header{
+				"Cache-Control": {"no-store"},
+				"Vary":          {"Accept", "X-Target"},
+				"Set-Cookie":    {"middleware=1", "session=abc; Path=/", "flash=; Path=/; Expires=Thu, 01 Jan 1970 00:00:00 GMT; Max-Age=0"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := build()
+			NewService(&Config{FS: fsys}).NewLayout().Set(p)
+
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.target != "" {
+				r.Header.Set("X-Target", tt.target)
+			}
+
+			w := httptest.NewRecorder()
+			w.Header().Set("Cache-Control", "public")
+			w.Header().Add("Set-Cookie", "middleware=1")
+			if err := p.WriteWithRequest(context.Background(), w, r); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			got := w.Header().Clone()
+			got.Del("Content-Type")
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("expected headers %v, got %v", tt.expected, got)
+			}
+		})
+	}
+
+	t.Run("reused tree", func(t *testing.T) {
+		p := build()
+		NewService(&Config{FS: fsys}).NewLayout().Set(p)
+
+		for i := 0; i < 3; i++ {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.Header.Set("X-Target", "content")
+
+			w := httptest.NewRecorder()
+			if err := p.WriteWithRequest(context.Background(), w, r); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if got := w.Header().Values("Vary"); !reflect.DeepEqual(got, []string{"Accept", "X-Target"}) {
+				t.Errorf("request %d: expected the headers of a single request, got %v", i+1, got)
+			}
+		}
+	})
+
+	t.Run("set before the parent", func(t *testing.T) {
+		child := NewID("child", "templates/content.html").SetResponseHeaders(map[string]string{"X-Child": "1"})
+		root := NewID("root", "templates/wrapper.html").SetResponseHeaders(map[string]string{"X-Root": "1", "X-Child": "root"})
+		layout := NewService(&Config{FS: fsys}).NewLayout().Set(child).Wrap(root)
+
+		w := httptest.NewRecorder()
+		if err := layout.WriteWithRequest(context.Background(), w, httptest.NewRequest(http.MethodGet, "/", nil)); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		// a header of a child takes precedence over the same header of its parents
+		if w.Header().Get("X-Child") != "1" || w.Header().Get("X-Root") != "1" {
+			t.Errorf("expected the headers of the wrapped content and the wrapper, got %v", w.Header())
+		}
+	})
+
+	t.Run("set from a child action", func(t *testing.T) {
+		child := NewID("child", "templates/content.html").WithAction(func(ctx context.Context, p *Partial, data *Data) (*Partial, error) {
+			p.SetResponseHeaders(map[string]string{"HX-Trigger": "saved"})
+			return p, nil
+		})
+		root := NewID("root", "templates/wrapper.html").With(child)
+		NewService(&Config{FS: fsys}).NewLayout().Set(root)
+
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("X-Target", "child")
+		if _, err := root.RenderWithRequest(context.Background(), r); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		// the headers of a child are set on its parents as well
+		if got := root.GetResponseHeaders(); !reflect.DeepEqual(got, map[string]string{"HX-Trigger": "saved"}) {
+			t.Errorf("expected the headers of the child on the root, got %v", got)
+		}
+	})
+
+	t.Run("render response", func(t *testing.T) {
+		p := build()
+		NewService(&Config{FS: fsys}).NewLayout().Set(p)
+
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("X-Target", "content")
+
+		_, res, err := p.RenderResponse(context.Background(), r)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if got := res.Header.Values("Vary"); !reflect.DeepEqual(got, []string{"Accept", "X-Target"}) {
+			t.Errorf("expected the headers set from the data, got %v", got)
+		}
+		if got := res.Header.Get("Cache-Control"); got != "no-store" {
+			t.Errorf("expected the headers set on the partial, got %q", got)
+		}
+	})
+}
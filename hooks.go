@@ -0,0 +1,128 @@
+package partial
+
+import (
+	"context"
+	"fmt"
+	"html/template"
+)
+
+type (
+	// RenderFunc renders a partial with its data.
+	RenderFunc func(ctx context.Context, p *Partial, data *Data) (template.HTML, error)
+
+	// Middleware wraps the render of a partial, it can change the data, time or rewrite the output,
+	// or return without calling next, e.g. to serve cached HTML.
+	Middleware func(next RenderFunc) RenderFunc
+
+	// BeforeRenderHook runs before the action and the templates of a partial, returning an error stops the render.
+	BeforeRenderHook func(ctx context.Context, p *Partial, data *Data) error
+
+	// AfterRenderHook runs after a partial was rendered or failed to render, it returns the output to use.
+	AfterRenderHook func(ctx context.Context, p *Partial, html template.HTML, err error) (template.HTML, error)
+
+	// renderHooks holds the hooks and middleware of a service or a partial.
+	renderHooks struct {
+		before     []BeforeRenderHook
+		after      []AfterRenderHook
+		middleware []Middleware
+	}
+)
+
+// BeforeRender registers a hook that runs before every partial rendered by the service.
+func (svc *Service) BeforeRender(hook BeforeRenderHook) *Service {
+	svc.hooks.before = append(svc.hooks.before, hook)
+	return svc
+}
+
+// AfterRender registers a hook that runs after every partial rendered by the service.
+func (svc *Service) AfterRender(hook AfterRenderHook) *Service {
+	svc.hooks.after = append(svc.hooks.after, hook)
+	return svc
+}
+
+// Use registers middleware that wraps the render of every partial rendered by the service.
+// The first middleware is the outermost, the middleware of the service wraps the middleware of the partial.
+func (svc *Service) Use(middleware ...Middleware) *Service {
+	svc.hooks.middleware = append(svc.hooks.middleware, middleware...)
+	return svc
+}
+
+// BeforeRender registers a hook that runs before the partial is rendered, after the hooks of the service.
+func (p *Partial) BeforeRender(hook BeforeRenderHook) *Partial {
+	p.hooks.before = append(p.hooks.before, hook)
+	return p
+}
+
+// AfterRender registers a hook that runs after the partial is rendered, before the hooks of the service.
+func (p *Partial) AfterRender(hook AfterRenderHook) *Partial {
+	p.hooks.after = append(p.hooks.after, hook)
+	return p
+}
+
+// Use registers middleware that wraps the render of the partial.
+func (p *Partial) Use(middleware ...Middleware) *Partial {
+	p.hooks.middleware = append(p.hooks.middleware, middleware...)
+	return p
+}
+
+func (p *Partial) getServiceHooks() *renderHooks {
+	if p.serviceHooks != nil {
+		return p.serviceHooks
+	}
+
+	if p.parent != nil {
+		return p.parent.getServiceHooks()
+	}
+
+	return nil
+}
+
+// renderFunc returns the render of the partial wrapped in the hooks and middleware of the service and the partial.
+// Every render path goes through it: the root, child, selection, action and OOB renders.
+func (p *Partial) renderFunc() RenderFunc {
+	var next RenderFunc = func(ctx context.Context, p *Partial, data *Data) (template.HTML, error) {
+		svc := p.getServiceHooks()
+		if svc == nil {
+			svc = &renderHooks{}
+		}
+
+		html, err := runBeforeRender(ctx, p, data, svc.before, p.hooks.before)
+		if err == nil {
+			html, err = render(ctx, p, data)
+		}
+
+		for _, hook := range p.hooks.after {
+			html, err = hook(ctx, p, html, err)
+		}
+		for _, hook := range svc.after {
+			html, err = hook(ctx, p, html, err)
+		}
+
+		return html, err
+	}
+
+	var middleware []Middleware
+	if svc := p.getServiceHooks(); svc != nil {
+		middleware = append(middleware, svc.middleware...)
+	}
+	middleware = append(middleware, p.hooks.middleware...)
+
+	for i := len(middleware) - 1; i >= 0; i-- {
+		next = middleware[i](next)
+	}
+
+	return next
+}
+
+func runBeforeRender(ctx context.Context, p *Partial, data *Data, hooks ...[]BeforeRenderHook) (template.HTML, error) {
+	for _, list := range hooks {
+		for _, hook := range list {
+			if err := hook(ctx, p, data); err != nil {
+				p.log(ctx).Error("error in before render hook", "error", err)
+				return "", p.renderError(fmt.Errorf("error in before render hook: %w", err))
+			}
+		}
+	}
+
+	return "", nil
+}
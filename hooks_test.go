@@ -0,0 +1,150 @@
+package partial
+
+import (
+	"context"
+	"errors"
+	"html/template"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestRenderHooks(t *testing.T) {
+	fsys := &InMemoryFS{
+		Files: map[string]string{
+			"templates/index.html":   `<main>{{ child "content" }}{{ child "footer" }}{{ action }}</main>`,
+			"templates/content.html": `<div>{{ .Data.User }}: {{ selection }}</div>`,
+			"templates/tab.html":     `<p>{{ .Data.User }}</p>`,
+			"templates/action.html":  `<i>action</i>`,
+			"templates/footer.html":  `<footer {{ oobSwapIfEnabled "true" }}>{{ .Data.User }}</footer>`,
+		},
+	}
+
+	build := func() *Partial {
+		content := NewID("content", "templates/content.html")
+		content.WithSelectMap("tab", map[string]*Partial{"tab": NewID("tab", "templates/tab.html")})
+
+		return NewID("root", "templates/index.html").
+			WithTemplateAction(func(ctx context.Context, p *Partial, data *Data) (*Partial, error) {
+				return NewID("action", "templates/action.html").SetParent(p), nil
+			}).
+			With(content).
+			WithOOB(NewID("footer", "templates/footer.html"))
+	}
+
+	var mu sync.Mutex
+	var calls []string
+	record := func(s string) {
+		mu.Lock()
+		calls = append(calls, s)
+		mu.Unlock()
+	}
+
+	svc := NewService(&Config{FS: fsys}).
+		BeforeRender(func(ctx context.Context, p *Partial, data *Data) error {
+			data.Data["User"] = "alice"
+			record("before " + p.id)
+			return nil
+		}).
+		AfterRender(func(ctx context.Context, p *Partial, html template.HTML, err error) (template.HTML, error) {
+			record("after " + p.id)
+			return html, err
+		}).
+		Use(func(next RenderFunc) RenderFunc {
+			return func(ctx context.Context, p *Partial, data *Data) (template.HTML, error) {
+				record("enter " + p.id)
+				return next(ctx, p, data)
+			}
+		})
+
+	t.Run("every render path", func(t *testing.T) {
+		calls = nil
+
+		r, _ := http.NewRequest(http.MethodGet, "/", nil)
+		out, err := svc.NewLayout().Set(build()).RenderWithRequest(context.Background(), r)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		expected := `<main><div>alice: <p>alice</p></div><footer >alice</footer><i>action</i></main>`
+		if string(out) != expected {
+			t.Errorf("expected %q, got %q", expected, out)
+		}
+
+		expectedCalls := []string{
+			"enter root", "before root",
+			"enter content", "before content",
+			"enter tab", "before tab", "after tab",
+			"after content",
+			"enter footer", "before footer", "after footer",
+			"enter action", "before action", "after action",
+			"after root",
+		}
+		if strings.Join(calls, ", ") != strings.Join(expectedCalls, ", ") {
+			t.Errorf("expected calls\n%v\ngot\n%v", expectedCalls, calls)
+		}
+	})
+
+	t.Run("oob render", func(t *testing.T) {
+		calls = nil
+
+		r, _ := http.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("X-Target", "content")
+		if _, err := svc.NewLayout().Set(build()).RenderWithRequest(context.Background(), r); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if !strings.Contains(strings.Join(calls, ", "), "enter footer, before footer, after footer") {
+			t.Errorf("expected the OOB footer to go through the hooks, got %v", calls)
+		}
+	})
+
+	t.Run("partial hooks and short-circuit", func(t *testing.T) {
+		cache := map[string]template.HTML{"footer": `<footer>cached</footer>`}
+
+		p := build()
+		p.Use(func(next RenderFunc) RenderFunc {
+			return func(ctx context.Context, p *Partial, data *Data) (template.HTML, error) {
+				html, err := next(ctx, p, data)
+				return "<!-- wrapped -->" + html, err
+			}
+		})
+		p.AfterRender(func(ctx context.Context, p *Partial, html template.HTML, err error) (template.HTML, error) {
+			return template.HTML(strings.ToUpper(string(html))), err
+		})
+
+		svc := NewService(&Config{FS: fsys}).Use(func(next RenderFunc) RenderFunc {
+			return func(ctx context.Context, p *Partial, data *Data) (template.HTML, error) {
+				if html, ok := cache[p.id]; ok {
+					return html, nil
+				}
+				return next(ctx, p, data)
+			}
+		})
+
+		r, _ := http.NewRequest(http.MethodGet, "/", nil)
+		out, err := svc.NewLayout().Set(p).RenderWithRequest(context.Background(), r)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		expected := `<!-- wrapped --><MAIN><DIV>: <P></P></DIV><FOOTER>CACHED</FOOTER><I>ACTION</I></MAIN>`
+		if string(out) != expected {
+			t.Errorf("expected %q, got %q", expected, out)
+		}
+	})
+
+	t.Run("before hook error", func(t *testing.T) {
+		denied := errors.New("denied")
+		svc := NewService(&Config{FS: fsys}).BeforeRender(func(ctx context.Context, p *Partial, data *Data) error {
+			return denied
+		})
+
+		r, _ := http.NewRequest(http.MethodGet, "/", nil)
+		_, err := svc.NewLayout().Set(build()).RenderWithRequest(context.Background(), r)
+		if !errors.Is(err, denied) {
+			t.Errorf("expected the hook error, got %v", err)
+		}
+	})
+}
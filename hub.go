@@ -0,0 +1,303 @@
+package partial
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/partial-coffee/go-partial/connector"
+)
+
+type (
+	// HubConfig configures a Hub.
+	HubConfig struct {
+		// Heartbeat is the interval of the keep-alive comments sent to idle clients, defaults to 30 seconds
+		Heartbeat time.Duration
+		// BufferSize is the number of events kept per topic for replay on reconnect, defaults to 64
+		BufferSize int
+		// ClientBuffer is the number of events queued per client before it is disconnected, defaults to 16
+		ClientBuffer int
+		// TopicParam is the query parameter holding the topics of a connection, defaults to "topic"
+		TopicParam string
+	}
+
+	// Hub keeps Server-Sent Events connections open per topic and pushes rendered partials to them.
+	Hub struct {
+		service     *Service
+		config      *HubConfig
+		mu          sync.Mutex
+		lastID      uint64
+		subscribers map[string]map[*hubSubscriber]struct{}
+		buffers     map[string][]HubEvent
+		closed      bool
+		done        chan struct{}
+	}
+
+	// HubEvent is a single event pushed to the clients of a topic.
+	HubEvent struct {
+		ID    uint64
+		Topic string
+		Event string
+		HTML  template.HTML
+	}
+
+	hubSubscriber struct {
+		events chan HubEvent
+		once   sync.Once
+	}
+)
+
+// ErrHubClosed is returned when publishing to a closed hub.
+var ErrHubClosed = errors.New("hub is closed")
+
+// NewHub returns a new Server-Sent Events hub that renders partials with the configuration of the service.
+func (svc *Service) NewHub(cfg *HubConfig) *Hub {
+	if cfg == nil {
+		cfg = &HubConfig{}
+	}
+
+	if cfg.Heartbeat <= 0 {
+		cfg.Heartbeat = 30 * time.Second
+	}
+
+	if cfg.BufferSize <= 0 {
+		cfg.BufferSize = 64
+	}
+
+	if cfg.ClientBuffer <= 0 {
+		cfg.ClientBuffer = 16
+	}
+
+	if cfg.TopicParam == "" {
+		cfg.TopicParam = "topic"
+	}
+
+	return &Hub{
+		service:     svc,
+		config:      cfg,
+		subscribers: make(map[string]map[*hubSubscriber]struct{}),
+		buffers:     make(map[string][]HubEvent),
+		done:        make(chan struct{}),
+	}
+}
+
+// Publish renders the partial with the given data and pushes it to every client subscribed to the topic.
+// The partial is rendered as out-of-band fragment in the dialect of the service connector, swapping the outer HTML
+// unless another strategy was configured, and sent as event named after the partial id.
+func (h *Hub) Publish(ctx context.Context, topic string, p *Partial, data map[string]any) error {
+	if p == nil {
+		return errors.New("partial is not initialized")
+	}
+
+	r, err := http.NewRequestWithContext(ctx, http.MethodGet, "/", nil)
+	if err != nil {
+		return fmt.Errorf("error creating request for hub render: %w", err)
+	}
+
+	clone := p.clone()
+	h.service.NewLayout().applyConfigToPartial(clone)
+	clone.request = r
+	clone.swapOOB = true
+	if clone.oobSwap == "" {
+		clone.oobSwap = connector.SwapOuterHTML
+	}
+	if data != nil {
+		clone.MergeData(data, true)
+	}
+
+	ctx, _ = withRenderState(ctx)
+	html, err := clone.renderOOB(ctx, r)
+	if err != nil {
+		clone.getLogger().Error("error rendering partial for hub", "id", clone.id, "topic", topic, "error", err)
+		return fmt.Errorf("error rendering partial '%s' for topic '%s': %w", clone.id, topic, err)
+	}
+
+	return h.PublishHTML(topic, clone.id, html)
+}
+
+// PublishHTML pushes an already rendered fragment as named event to every client subscribed to the topic.
+func (h *Hub) PublishHTML(topic, event string, html template.HTML) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		return ErrHubClosed
+	}
+
+	h.lastID++
+	e := HubEvent{ID: h.lastID, Topic: topic, Event: event, HTML: html}
+
+	buffer := append(h.buffers[topic], e)
+	if len(buffer) > h.config.BufferSize {
+		buffer = buffer[len(buffer)-h.config.BufferSize:]
+	}
+	h.buffers[topic] = buffer
+
+	for sub := range h.subscribers[topic] {
+		select {
+		case sub.events <- e:
+		default:
+			// the client is too slow, disconnect it so it can reconnect and replay from the buffer
+			h.unsubscribeLocked(sub)
+		}
+	}
+
+	return nil
+}
+
+// Subscribe registers a subscriber for the topics and returns its events.
+// Buffered events with an id greater than lastEventID are replayed first, pass 0 to skip the replay.
+// The returned function unsubscribes; the channel is also closed when the hub is closed or the subscriber falls behind.
+func (h *Hub) Subscribe(lastEventID uint64, topics ...string) (<-chan HubEvent, func()) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var replay []HubEvent
+	if lastEventID > 0 {
+		for _, topic := range topics {
+			for _, e := range h.buffers[topic] {
+				if e.ID > lastEventID {
+					replay = append(replay, e)
+				}
+			}
+		}
+		sort.Slice(replay, func(i, j int) bool { return replay[i].ID < replay[j].ID })
+	}
+
+	sub := &hubSubscriber{events: make(chan HubEvent, h.config.ClientBuffer+len(replay))}
+	for _, e := range replay {
+		sub.events <- e
+	}
+
+	if h.closed {
+		sub.close()
+		return sub.events, func() {}
+	}
+
+	for _, topic := range topics {
+		if h.subscribers[topic] == nil {
+			h.subscribers[topic] = make(map[*hubSubscriber]struct{})
+		}
+		h.subscribers[topic][sub] = struct{}{}
+	}
+
+	return sub.events, func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		h.unsubscribeLocked(sub)
+	}
+}
+
+// Close disconnects every client, publishing afterwards returns ErrHubClosed.
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		return
+	}
+
+	h.closed = true
+	close(h.done)
+	for _, subs := range h.subscribers {
+		for sub := range subs {
+			sub.close()
+		}
+	}
+	h.subscribers = make(map[string]map[*hubSubscriber]struct{})
+}
+
+// ServeHTTP streams the events of the topics given in the query to the client.
+// Reconnecting clients send the Last-Event-ID header and receive the buffered events they missed.
+func (h *Hub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	topics := r.URL.Query()[h.config.TopicParam]
+	if len(topics) == 0 {
+		http.Error(w, "no topic given", http.StatusBadRequest)
+		return
+	}
+
+	var lastEventID uint64
+	if v := r.Header.Get("Last-Event-ID"); v != "" {
+		lastEventID, _ = strconv.ParseUint(v, 10, 64)
+	}
+
+	events, unsubscribe := h.Subscribe(lastEventID, topics...)
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(h.config.Heartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-h.done:
+			return
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		case e, ok := <-events:
+			if !ok {
+				return
+			}
+			if err := writeHubEvent(w, e); err != nil {
+				h.service.config.Logger.Error("error writing event to client", "topic", e.Topic, "error", err)
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+func (h *Hub) unsubscribeLocked(sub *hubSubscriber) {
+	for topic, subs := range h.subscribers {
+		delete(subs, sub)
+		if len(subs) == 0 {
+			delete(h.subscribers, topic)
+		}
+	}
+	sub.close()
+}
+
+func (s *hubSubscriber) close() {
+	s.once.Do(func() {
+		close(s.events)
+	})
+}
+
+// writeHubEvent writes the event in the text/event-stream format, every line of the fragment becomes a data line.
+func writeHubEvent(w http.ResponseWriter, e HubEvent) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "id: %d\n", e.ID)
+	if e.Event != "" {
+		fmt.Fprintf(&b, "event: %s\n", e.Event)
+	}
+	for _, line := range strings.Split(string(e.HTML), "\n") {
+		fmt.Fprintf(&b, "data: %s\n", strings.TrimSuffix(line, "\r"))
+	}
+	b.WriteString("\n")
+
+	_, err := w.Write([]byte(b.String()))
+	return err
+}
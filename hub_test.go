@@ -0,0 +1,150 @@
+package partial
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/partial-coffee/go-partial/connector"
+)
+
+func newTestHub(t *testing.T, cfg *HubConfig) *Hub {
+	fsys := &InMemoryFS{
+		Files: map[string]string{
+			"templates/badge.html": `<span id="badge" {{ oobSwapIfEnabled "true" }}>{{ .Data.Count }}</span>`,
+		},
+	}
+
+	hub := NewService(&Config{FS: fsys, Connector: connector.NewHTMX(nil)}).NewHub(cfg)
+	t.Cleanup(hub.Close)
+
+	return hub
+}
+
+func TestHubPublish(t *testing.T) {
+	hub := newTestHub(t, nil)
+	badge := New("templates/badge.html").ID("badge")
+
+	events, unsubscribe := hub.Subscribe(0, "cart")
+	defer unsubscribe()
+
+	if err := hub.Publish(context.Background(), "cart", badge, map[string]any{"Count": 2}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := hub.Publish(context.Background(), "other", badge, map[string]any{"Count": 5}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	select {
+	case e := <-events:
+		expected := `<span id="badge" hx-swap-oob="outerHTML">2</span>`
+		if e.ID != 1 || e.Event != "badge" || string(e.HTML) != expected {
+			t.Errorf("unexpected event %+v", e)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected event")
+	}
+
+	select {
+	case e := <-events:
+		t.Errorf("unexpected event for other topic %+v", e)
+	default:
+	}
+
+	// reconnecting clients get the events they missed
+	replay, unsubscribeReplay := hub.Subscribe(1, "cart", "other")
+	defer unsubscribeReplay()
+
+	select {
+	case e := <-replay:
+		if e.ID != 2 || e.Topic != "other" {
+			t.Errorf("unexpected replayed event %+v", e)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected replayed event")
+	}
+}
+
+func TestHubBuffer(t *testing.T) {
+	hub := newTestHub(t, &HubConfig{BufferSize: 2, ClientBuffer: 1})
+
+	slow, unsubscribe := hub.Subscribe(0, "t")
+	defer unsubscribe()
+
+	for i := 0; i < 4; i++ {
+		if err := hub.PublishHTML("t", "e", "<p></p>"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	// the slow subscriber got the first event and was disconnected afterwards
+	var received int
+	for range slow {
+		received++
+	}
+	if received != 1 {
+		t.Errorf("expected 1 event before disconnect, got %d", received)
+	}
+
+	replay, unsubscribeReplay := hub.Subscribe(1, "t")
+	defer unsubscribeReplay()
+
+	if e := <-replay; e.ID != 3 {
+		t.Errorf("expected replay to start at the oldest buffered event 3, got %d", e.ID)
+	}
+
+	hub.Close()
+	if err := hub.PublishHTML("t", "e", ""); err != ErrHubClosed {
+		t.Errorf("expected ErrHubClosed, got %v", err)
+	}
+}
+
+func TestHubServeHTTP(t *testing.T) {
+	hub := newTestHub(t, &HubConfig{Heartbeat: 20 * time.Millisecond})
+
+	server := httptest.NewServer(hub)
+	defer server.Close()
+
+	_ = hub.PublishHTML("news", "headline", "<h1>old</h1>")
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL+"?topic=news", nil)
+	req.Header.Set("Last-Event-ID", "0")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("expected text/event-stream, got %s", ct)
+	}
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		_ = hub.PublishHTML("news", "headline", "<h1>first</h1>\n<p>second</p>")
+	}()
+
+	reader := bufio.NewReader(resp.Body)
+	var lines []string
+	for len(lines) < 4 {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		line = strings.TrimSuffix(line, "\n")
+		if line == "" || line == ": heartbeat" {
+			continue
+		}
+		lines = append(lines, line)
+	}
+
+	expected := []string{"id: 2", "event: headline", "data: <h1>first</h1>", "data: <p>second</p>"}
+	if strings.Join(lines, "|") != strings.Join(expected, "|") {
+		t.Errorf("expected %v, got %v", expected, lines)
+	}
+}
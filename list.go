@@ -0,0 +1,496 @@
+package partial
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/partial-coffee/go-partial/connector"
+)
+
+var (
+	// ListVersionHeader is the request header carrying the version token of a keyed list.
+	ListVersionHeader = "X-List-Version"
+	// ListVersionField is the form field carrying the version token of a keyed list.
+	ListVersionField = "_list_version"
+)
+
+type (
+	// ListItem is a single item of a keyed list.
+	ListItem struct {
+		// Key identifies the item, it must be stable across requests and unique within the list
+		Key string
+		// Data is merged into the data of the item partial
+		Data map[string]any
+	}
+
+	// ListState holds the keys and content hashes of a rendered list.
+	ListState struct {
+		Keys   []string          `json:"k"`
+		Hashes map[string]string `json:"h"`
+	}
+
+	// ListStore stores the last rendered list state per client.
+	// Without a store the state is encoded in the version token itself.
+	ListStore interface {
+		Load(ctx context.Context, token string) (ListState, bool)
+		Save(ctx context.Context, token string, state ListState) error
+	}
+
+	// InMemoryListStore is a ListStore that keeps the list states in memory of a single process.
+	// Every full render stores a state under a new token, so states expire after TTL and the oldest
+	// states are evicted beyond MaxEntries. Use a shared store when running more than one instance.
+	InMemoryListStore struct {
+		// TTL is how long a state is kept after it was saved
+		TTL time.Duration
+		// MaxEntries is the maximum number of states kept, 0 means no maximum
+		MaxEntries int
+
+		mu        sync.Mutex
+		states    map[string]storedListState
+		nextSweep time.Time
+		now       func() time.Time
+	}
+
+	storedListState struct {
+		state   ListState
+		expires time.Time
+	}
+
+	// list holds the configuration of a keyed list partial.
+	list struct {
+		item      *Partial
+		items     []ListItem
+		container string
+		store     ListStore
+	}
+
+	// renderedItem is a list item rendered with the item partial.
+	renderedItem struct {
+		key  string
+		id   string
+		html template.HTML
+		hash string
+	}
+)
+
+// NewInMemoryListStore returns a new in-memory list store keeping states for 30 minutes, at most 10000 of them.
+func NewInMemoryListStore() *InMemoryListStore {
+	return &InMemoryListStore{
+		TTL:        30 * time.Minute,
+		MaxEntries: 10000,
+		states:     make(map[string]storedListState),
+		now:        time.Now,
+	}
+}
+
+// Load returns the list state stored for the token, expired states are not returned.
+func (s *InMemoryListStore) Load(_ context.Context, token string) (ListState, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.states[token]
+	if !ok || !s.now().Before(stored.expires) {
+		return ListState{}, false
+	}
+
+	return stored.state, true
+}
+
+// Save stores the list state for the token, removing expired states and evicting the oldest when full.
+func (s *InMemoryListStore) Save(_ context.Context, token string, state ListState) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if !now.Before(s.nextSweep) {
+		for k, stored := range s.states {
+			if !now.Before(stored.expires) {
+				delete(s.states, k)
+			}
+		}
+		s.nextSweep = now.Add(s.TTL / 2)
+	}
+
+	if _, ok := s.states[token]; !ok && s.MaxEntries > 0 && len(s.states) >= s.MaxEntries {
+		s.evictOldest()
+	}
+
+	s.states[token] = storedListState{state: state, expires: now.Add(s.TTL)}
+	return nil
+}
+
+// Len returns the number of states kept.
+func (s *InMemoryListStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.states)
+}
+
+// evictOldest removes the state that expires first, the caller must hold the lock.
+func (s *InMemoryListStore) evictOldest() {
+	var oldest string
+	var expires time.Time
+	for k, stored := range s.states {
+		if oldest == "" || stored.expires.Before(expires) {
+			oldest, expires = k, stored.expires
+		}
+	}
+
+	delete(s.states, oldest)
+}
+
+// WithList turns the partial into a keyed list whose items are rendered with the item partial.
+// The list template renders the items with the listItems template function and the version token with listVersion.
+// The root element of the item template must use .Data.ItemID as id, the container element of the list
+// must use the id of the list partial.
+//
+// When a partial request targets the list and carries the version token of a previous render,
+// only the changes are sent as OOB updates: appended or prepended items, replaced items and deleted items.
+// The main fragment is empty in that case, so the client should not swap the target itself (e.g. hx-swap="none").
+// Lists that were reordered or got items inserted in the middle are rendered in full.
+func (p *Partial) WithList(item *Partial) *Partial {
+	p.With(item)
+
+	p.mu.Lock()
+	p.list = &list{item: item}
+	p.mu.Unlock()
+
+	return p
+}
+
+// SetListItems sets the items of a keyed list.
+func (p *Partial) SetListItems(items []ListItem) *Partial {
+	if p.list == nil {
+		p.getLogger().Warn("partial is not a list, call WithList first", "id", p.id)
+		return p
+	}
+
+	p.list.items = items
+	return p
+}
+
+// SetListContainer sets the selector of the element the list items are appended to, by default the id of the list partial.
+func (p *Partial) SetListContainer(selector string) *Partial {
+	if p.list == nil {
+		p.getLogger().Warn("partial is not a list, call WithList first", "id", p.id)
+		return p
+	}
+
+	p.list.container = selector
+	return p
+}
+
+// SetListStore stores the list state server-side, the version token then only identifies the client.
+func (p *Partial) SetListStore(store ListStore) *Partial {
+	if p.list == nil {
+		p.getLogger().Warn("partial is not a list, call WithList first", "id", p.id)
+		return p
+	}
+
+	p.list.store = store
+	return p
+}
+
+// renderListItems renders every item of the list with the item partial.
+func (p *Partial) renderListItems(ctx context.Context, r *http.Request) ([]renderedItem, error) {
+	out := make([]renderedItem, 0, len(p.list.items))
+	for _, item := range p.list.items {
+		itemClone := p.list.item.clone()
+		itemClone.parent = p
+		itemClone.MergeData(item.Data, true)
+		itemClone.MergeData(map[string]any{"Key": item.Key, "ItemID": p.listItemID(item.Key)}, true)
+
+		html, err := itemClone.renderSelf(ctx, r)
+		if err != nil {
+			return nil, fmt.Errorf("error rendering list item '%s': %w", item.Key, err)
+		}
+
+		sum := sha256.Sum256([]byte(html))
+		out = append(out, renderedItem{
+			key:  item.Key,
+			id:   p.listItemID(item.Key),
+			html: html,
+			hash: hex.EncodeToString(sum[:8]),
+		})
+	}
+
+	return out, nil
+}
+
+// renderListDiff renders only the changes since the previous render of the list.
+// It returns false if the changes cannot be expressed as OOB updates and the list must be rendered in full.
+func (p *Partial) renderListDiff(ctx context.Context, r *http.Request) (template.HTML, bool, error) {
+	token := getListVersion(r)
+	if token == "" {
+		return "", false, nil
+	}
+
+	previous, ok := p.loadListState(ctx, token)
+	if !ok {
+		return "", false, nil
+	}
+
+	items, err := p.renderListItems(ctx, r)
+	if err != nil {
+		return "", false, err
+	}
+
+	current := newListState(items)
+	appended, prepended, ok := diffListKeys(previous.Keys, current.Keys)
+	if !ok {
+		return "", false, nil
+	}
+
+	renderer := p.getOOBRenderer()
+	container := p.list.container
+	byKey := make(map[string]renderedItem, len(items))
+	for _, item := range items {
+		byKey[item.key] = item
+	}
+
+	var out template.HTML
+	for _, key := range previous.Keys {
+		if _, exists := current.Hashes[key]; !exists {
+			out += template.HTML(renderer.RenderOOB(p.listItemID(key), connector.SwapDelete, "", ""))
+		}
+	}
+
+	// changed items replace their previous version, the swap attribute is added to the item that was already rendered
+	swapAttr := renderer.OOBSwapAttr(connector.SwapOuterHTML, "")
+	for _, key := range current.Keys {
+		if hash, existed := previous.Hashes[key]; existed && hash != current.Hashes[key] {
+			item := string(byKey[key].html)
+			if swapAttr != "" {
+				if item, ok = injectAttributes(item, " "+swapAttr); !ok {
+					return "", false, nil
+				}
+			}
+			out += template.HTML(renderer.RenderOOB(byKey[key].id, connector.SwapOuterHTML, "", item))
+		}
+	}
+
+	// items are inserted one at a time, so prepended items go in reverse order
+	for i := len(prepended) - 1; i >= 0; i-- {
+		out += template.HTML(renderer.WrapOOB(p.id, connector.SwapAfterBegin, container, string(byKey[prepended[i]].html)))
+	}
+
+	for _, key := range appended {
+		out += template.HTML(renderer.WrapOOB(p.id, connector.SwapBeforeEnd, container, string(byKey[key].html)))
+	}
+
+	version, err := p.listVersionInput(ctx, token, current, true)
+	if err != nil {
+		return "", false, err
+	}
+
+	return out + version, true, nil
+}
+
+// listVersionInput returns the hidden input carrying the version token of the list.
+func (p *Partial) listVersionInput(ctx context.Context, token string, state ListState, swapOOB bool) (template.HTML, error) {
+	token, err := p.saveListState(ctx, token, state)
+	if err != nil {
+		return "", err
+	}
+
+	id := p.id + "-version"
+	var attr string
+	if swapOOB {
+		attr = " " + p.getOOBRenderer().OOBSwapAttr(connector.SwapOuterHTML, "")
+	}
+
+	input := fmt.Sprintf(`<input type="hidden" id="%s" name="%s" value="%s"%s>`,
+		template.HTMLEscapeString(id), template.HTMLEscapeString(ListVersionField), template.HTMLEscapeString(token), attr)
+	if swapOOB {
+		input = p.getOOBRenderer().RenderOOB(id, connector.SwapOuterHTML, "", input)
+	}
+
+	return template.HTML(input), nil
+}
+
+func (p *Partial) loadListState(ctx context.Context, token string) (ListState, bool) {
+	if p.list.store != nil {
+		return p.list.store.Load(ctx, token)
+	}
+
+	b, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return ListState{}, false
+	}
+
+	var state ListState
+	if err = json.Unmarshal(b, &state); err != nil {
+		return ListState{}, false
+	}
+
+	return state, true
+}
+
+// saveListState stores the state and returns the version token for the next request.
+func (p *Partial) saveListState(ctx context.Context, token string, state ListState) (string, error) {
+	if p.list.store == nil {
+		b, err := json.Marshal(state)
+		if err != nil {
+			return "", fmt.Errorf("error encoding list state: %w", err)
+		}
+		return base64.RawURLEncoding.EncodeToString(b), nil
+	}
+
+	if token == "" {
+		b := make([]byte, 16)
+		if _, err := rand.Read(b); err != nil {
+			return "", fmt.Errorf("error generating list token: %w", err)
+		}
+		token = hex.EncodeToString(b)
+	}
+
+	if err := p.list.store.Save(ctx, token, state); err != nil {
+		return "", fmt.Errorf("error saving list state: %w", err)
+	}
+
+	return token, nil
+}
+
+// copy returns a copy of the list, the items set on the copy do not change the original.
+func (l *list) copy() *list {
+	if l == nil {
+		return nil
+	}
+
+	c := *l
+	c.items = append([]ListItem(nil), l.items...)
+	return &c
+}
+
+func (p *Partial) listItemID(key string) string {
+	return p.id + "-" + key
+}
+
+func (p *Partial) isListDiffRequest(r *http.Request) bool {
+	if p.list == nil || r == nil || p.swapOOB {
+		return false
+	}
+
+	c := p.getConnector()
+	if c == nil || !c.RenderPartial(r) {
+		return false
+	}
+
+	return c.GetTargetValue(r) == p.id
+}
+
+func newListState(items []renderedItem) ListState {
+	state := ListState{
+		Keys:   make([]string, 0, len(items)),
+		Hashes: make(map[string]string, len(items)),
+	}
+	for _, item := range items {
+		state.Keys = append(state.Keys, item.key)
+		state.Hashes[item.key] = item.hash
+	}
+	return state
+}
+
+// diffListKeys returns the keys that were added at the end and at the start of the list.
+// It returns false if the remaining keys changed order or keys were inserted in the middle.
+func diffListKeys(previous, current []string) (appended, prepended []string, ok bool) {
+	existed := make(map[string]struct{}, len(previous))
+	for _, key := range previous {
+		existed[key] = struct{}{}
+	}
+
+	exists := make(map[string]struct{}, len(current))
+	for _, key := range current {
+		exists[key] = struct{}{}
+	}
+
+	var kept []string
+	for _, key := range previous {
+		if _, ok := exists[key]; ok {
+			kept = append(kept, key)
+		}
+	}
+
+	// split the current keys into new keys before, between and after the kept keys
+	i := 0
+	for _, key := range current {
+		if _, ok := existed[key]; ok {
+			if i >= len(kept) || kept[i] != key {
+				return nil, nil, false
+			}
+			i++
+			continue
+		}
+
+		switch {
+		case i == 0:
+			prepended = append(prepended, key)
+		case i == len(kept):
+			appended = append(appended, key)
+		default:
+			return nil, nil, false
+		}
+	}
+
+	// without kept keys every new key counts as prepended, append them instead to keep the order simple
+	if len(kept) == 0 {
+		appended, prepended = prepended, nil
+	}
+
+	return appended, prepended, true
+}
+
+func getListVersion(r *http.Request) string {
+	if r == nil {
+		return ""
+	}
+
+	if token := r.Header.Get(ListVersionHeader); token != "" {
+		return token
+	}
+
+	return r.FormValue(ListVersionField)
+}
+
+func listItemsFunc(p *Partial, rendered func() ([]renderedItem, error)) func() template.HTML {
+	return func() template.HTML {
+		items, err := rendered()
+		if err != nil {
+			p.getLogger().Error("error rendering list items", "id", p.id, "error", err)
+			return template.HTML(fmt.Sprintf("error rendering list items of '%s': %v", p.id, err))
+		}
+
+		var out template.HTML
+		for _, item := range items {
+			out += item.html
+		}
+		return out
+	}
+}
+
+func listVersionFunc(p *Partial, data *Data, rendered func() ([]renderedItem, error)) func() template.HTML {
+	return func() template.HTML {
+		items, err := rendered()
+		if err != nil {
+			p.getLogger().Error("error rendering list items", "id", p.id, "error", err)
+			return ""
+		}
+
+		input, err := p.listVersionInput(data.Ctx, getListVersion(data.Request), newListState(items), false)
+		if err != nil {
+			p.getLogger().Error("error rendering list version", "id", p.id, "error", err)
+			return ""
+		}
+		return input
+	}
+}
@@ -0,0 +1,211 @@
+package partial
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/partial-coffee/go-partial/connector"
+)
+
+func TestKeyedList(t *testing.T) {
+	fsys := &InMemoryFS{
+		Files: map[string]string{
+			"templates/rows.html": `<ul id="rows">{{ listItems }}</ul>{{ listVersion }}`,
+			"templates/row.html":  `<li id="{{ .Data.ItemID }}" {{ oobSwapIfEnabled "true" }}>{{ .Data.Title }}</li>`,
+		},
+	}
+
+	versionRe := regexp.MustCompile(`name="_list_version" value="([^"]+)"`)
+
+	for _, store := range []ListStore{nil, NewInMemoryListStore()} {
+		svc := NewService(&Config{FS: fsys, Connector: connector.NewHTMX(nil)})
+
+		render := func(token string, items []ListItem) string {
+			rows := New("templates/rows.html").ID("rows").WithList(New("templates/row.html").ID("row"))
+			rows.SetListItems(items)
+			if store != nil {
+				rows.SetListStore(store)
+			}
+
+			request, _ := http.NewRequest(http.MethodGet, "/", nil)
+			if token != "" {
+				request.Header.Set("HX-Request", "true")
+				request.Header.Set("HX-Target", "rows")
+				request.Header.Set(ListVersionHeader, token)
+			}
+
+			out, err := svc.NewLayout().Set(rows).RenderWithRequest(context.Background(), request)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			return string(out)
+		}
+
+		item := func(key, title string) ListItem {
+			return ListItem{Key: key, Data: map[string]any{"Title": title}}
+		}
+
+		out := render("", []ListItem{item("1", "one"), item("2", "two"), item("3", "three")})
+		if !strings.HasPrefix(out, `<ul id="rows"><li id="rows-1" >one</li><li id="rows-2" >two</li><li id="rows-3" >three</li></ul>`) {
+			t.Fatalf("unexpected full render: %s", out)
+		}
+
+		match := versionRe.FindStringSubmatch(out)
+		if match == nil {
+			t.Fatalf("expected version token in %s", out)
+		}
+
+		out = render(match[1], []ListItem{item("0", "zero"), item("1", "ONE"), item("3", "three"), item("4", "four")})
+		expected := `<div id="rows-2" hx-swap-oob="delete"></div>` +
+			`<li hx-swap-oob="outerHTML" id="rows-1" >ONE</li>` +
+			`<template hx-swap-oob="afterbegin:#rows"><li id="rows-0" >zero</li></template>` +
+			`<template hx-swap-oob="beforeend:#rows"><li id="rows-4" >four</li></template>`
+		if !strings.HasPrefix(out, expected) {
+			t.Errorf("expected diff %s, got %s", expected, out)
+		}
+		if !strings.Contains(out, `id="rows-version"`) || !strings.Contains(out, `hx-swap-oob="outerHTML">`) {
+			t.Errorf("expected OOB version input, got %s", out)
+		}
+
+		match = versionRe.FindStringSubmatch(out)
+		if match == nil {
+			t.Fatalf("expected version token in %s", out)
+		}
+
+		// reordered lists fall back to a full render
+		out = render(match[1], []ListItem{item("3", "three"), item("1", "ONE")})
+		if !strings.HasPrefix(out, `<ul id="rows"><li id="rows-3" >three</li><li id="rows-1" >ONE</li></ul>`) {
+			t.Errorf("expected full render, got %s", out)
+		}
+	}
+}
+
+func TestKeyedListReusedTree(t *testing.T) {
+	fsys := &InMemoryFS{
+		Files: map[string]string{
+			"templates/rows.html": `<ul id="rows">{{ listItems }}</ul>{{ listVersion }}`,
+			"templates/row.html":  `<li id="{{ .Data.ItemID }}">{{ .Data.Title }}</li>`,
+		},
+	}
+
+	versionRe := regexp.MustCompile(`name="_list_version" value="([^"]+)"`)
+	svc := NewService(&Config{FS: fsys, Connector: connector.NewHTMX(nil)})
+
+	renders := 0
+	row := New("templates/row.html").ID("row").BeforeRender(func(ctx context.Context, p *Partial, data *Data) error {
+		renders++
+		return nil
+	})
+
+	var items []ListItem
+	rows := New("templates/rows.html").ID("rows").WithList(row).WithAction(func(ctx context.Context, p *Partial, data *Data) (*Partial, error) {
+		return p.SetListItems(items), nil
+	})
+
+	render := func(token string) string {
+		request, _ := http.NewRequest(http.MethodGet, "/", nil)
+		if token != "" {
+			request.Header.Set("HX-Request", "true")
+			request.Header.Set("HX-Target", "rows")
+			request.Header.Set(ListVersionHeader, token)
+		}
+
+		out, err := svc.NewLayout().Set(rows).RenderWithRequest(context.Background(), request)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(rows.list.items) != 0 {
+			t.Errorf("expected the items set by the action to stay with the render, got %v", rows.list.items)
+		}
+		return string(out)
+	}
+
+	items = []ListItem{{Key: "1", Data: map[string]any{"Title": "one"}}, {Key: "2", Data: map[string]any{"Title": "two"}}}
+	out := render("")
+	match := versionRe.FindStringSubmatch(out)
+	if match == nil {
+		t.Fatalf("expected version token in %s", out)
+	}
+
+	renders = 0
+	items = []ListItem{{Key: "1", Data: map[string]any{"Title": "ONE"}}, {Key: "2", Data: map[string]any{"Title": "two"}}}
+	out = render(match[1])
+	if !strings.HasPrefix(out, `<li hx-swap-oob="outerHTML" id="rows-1">ONE</li><input`) {
+		t.Errorf("expected the changed item, got %s", out)
+	}
+	if renders != len(items) {
+		t.Errorf("expected every item to render once, got %d renders", renders)
+	}
+}
+
+func TestDiffListKeys(t *testing.T) {
+	testCases := []struct {
+		name      string
+		previous  []string
+		current   []string
+		appended  []string
+		prepended []string
+		ok        bool
+	}{
+		{"unchanged", []string{"a", "b"}, []string{"a", "b"}, nil, nil, true},
+		{"appended", []string{"a"}, []string{"a", "b", "c"}, []string{"b", "c"}, nil, true},
+		{"prepended", []string{"c"}, []string{"a", "b", "c"}, nil, []string{"a", "b"}, true},
+		{"removed", []string{"a", "b", "c"}, []string{"a", "c"}, nil, nil, true},
+		{"from empty", nil, []string{"a", "b"}, []string{"a", "b"}, nil, true},
+		{"inserted", []string{"a", "c"}, []string{"a", "b", "c"}, nil, nil, false},
+		{"reordered", []string{"a", "b"}, []string{"b", "a"}, nil, nil, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			appended, prepended, ok := diffListKeys(tc.previous, tc.current)
+			if ok != tc.ok {
+				t.Fatalf("expected ok %v, got %v", tc.ok, ok)
+			}
+			if strings.Join(appended, ",") != strings.Join(tc.appended, ",") {
+				t.Errorf("expected appended %v, got %v", tc.appended, appended)
+			}
+			if strings.Join(prepended, ",") != strings.Join(tc.prepended, ",") {
+				t.Errorf("expected prepended %v, got %v", tc.prepended, prepended)
+			}
+		})
+	}
+}
+
+func TestInMemoryListStore(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	store := NewInMemoryListStore()
+	store.TTL = time.Minute
+	store.MaxEntries = 2
+	store.now = func() time.Time { return now }
+
+	state := ListState{Keys: []string{"1"}}
+	_ = store.Save(ctx, "a", state)
+	now = now.Add(10 * time.Second)
+	_ = store.Save(ctx, "b", state)
+	now = now.Add(10 * time.Second)
+	_ = store.Save(ctx, "c", state)
+
+	if _, ok := store.Load(ctx, "a"); ok {
+		t.Errorf("expected the oldest state to be evicted")
+	}
+	if _, ok := store.Load(ctx, "c"); !ok {
+		t.Errorf("expected the newest state to be kept")
+	}
+
+	now = now.Add(time.Minute)
+	if _, ok := store.Load(ctx, "c"); ok {
+		t.Errorf("expected the state to be expired")
+	}
+
+	_ = store.Save(ctx, "d", state)
+	if store.Len() != 1 {
+		t.Errorf("expected expired states to be removed, got %d states", store.Len())
+	}
+}
@@ -0,0 +1,140 @@
+package partial
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+)
+
+type (
+	// ContextLogger is a logger that receives the context of the request, *slog.Logger implements it,
+	// so any slog.Handler can be used with slog.New. When the Logger of the Config implements it,
+	// the context methods are used and the handler can read request-scoped values such as a trace id from the context.
+	ContextLogger interface {
+		DebugContext(ctx context.Context, msg string, args ...any)
+		InfoContext(ctx context.Context, msg string, args ...any)
+		WarnContext(ctx context.Context, msg string, args ...any)
+		ErrorContext(ctx context.Context, msg string, args ...any)
+	}
+
+	// partialLogger adds the details of the partial and the request to every log call.
+	partialLogger struct {
+		logger Logger
+		p      *Partial
+		ctx    context.Context
+	}
+
+	logAttrsKey struct{}
+)
+
+// WithLogAttrs returns a context carrying attributes added to every log call of the render, e.g. a request id.
+// The attributes come in key-value pairs or as slog.Attr, like the arguments of slog.
+func WithLogAttrs(ctx context.Context, args ...any) context.Context {
+	existing, _ := ctx.Value(logAttrsKey{}).([]any)
+	return context.WithValue(ctx, logAttrsKey{}, append(append([]any{}, existing...), args...))
+}
+
+// logAttrs returns the attributes added to the context with WithLogAttrs.
+func logAttrs(ctx context.Context) []any {
+	if ctx == nil {
+		return nil
+	}
+
+	attrs, _ := ctx.Value(logAttrsKey{}).([]any)
+	return attrs
+}
+
+// log returns a logger adding the partial id path, templates, the target and action values
+// and the attributes of the context to every call.
+func (p *Partial) log(ctx context.Context) partialLogger {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	return partialLogger{logger: p.getLogger(), p: p, ctx: ctx}
+}
+
+// log returns a logger adding the attributes of the context to every call.
+func (svc *Service) log(ctx context.Context) partialLogger {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	var logger Logger = slog.Default().WithGroup("partial")
+	if svc.config.Logger != nil {
+		logger = svc.config.Logger
+	}
+
+	return partialLogger{logger: logger, ctx: ctx}
+}
+
+// Debug and Info are only written by a ContextLogger, the Logger interface has no such levels.
+func (l partialLogger) Debug(msg string, args ...any) {
+	l.write(slog.LevelDebug, msg, args)
+}
+
+func (l partialLogger) Info(msg string, args ...any) {
+	l.write(slog.LevelInfo, msg, args)
+}
+
+func (l partialLogger) Warn(msg string, args ...any) {
+	l.write(slog.LevelWarn, msg, args)
+}
+
+func (l partialLogger) Error(msg string, args ...any) {
+	l.write(slog.LevelError, msg, args)
+}
+
+func (l partialLogger) write(level slog.Level, msg string, args []any) {
+	args = append(l.attrs(), args...)
+
+	if cl, ok := l.logger.(ContextLogger); ok {
+		switch level {
+		case slog.LevelDebug:
+			cl.DebugContext(l.ctx, msg, args...)
+		case slog.LevelInfo:
+			cl.InfoContext(l.ctx, msg, args...)
+		case slog.LevelWarn:
+			cl.WarnContext(l.ctx, msg, args...)
+		default:
+			cl.ErrorContext(l.ctx, msg, args...)
+		}
+		return
+	}
+
+	switch level {
+	case slog.LevelWarn:
+		l.logger.Warn(msg, args...)
+	case slog.LevelError:
+		l.logger.Error(msg, args...)
+	}
+}
+
+func (l partialLogger) attrs() []any {
+	p := l.p
+	if p == nil {
+		return logAttrs(l.ctx)
+	}
+
+	var ids []string
+	for current := p; current != nil; current = current.parent {
+		ids = append([]string{current.id}, ids...)
+	}
+
+	attrs := []any{"path", strings.Join(ids, "/")}
+	if len(p.templates) > 0 {
+		attrs = append(attrs, "templates", strings.Join(p.templates, ","))
+	}
+
+	// partials also log before they have a request, e.g. when they are configured
+	if c, r := p.getConnector(), p.getRequest(); c != nil && r != nil && r.URL != nil {
+		if v := c.GetTargetValue(r); v != "" {
+			attrs = append(attrs, "target", v)
+		}
+		if v := c.GetActionValue(r); v != "" {
+			attrs = append(attrs, "action", v)
+		}
+	}
+
+	return append(attrs, logAttrs(l.ctx)...)
+}
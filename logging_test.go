@@ -0,0 +1,123 @@
+package partial
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/partial-coffee/go-partial/connector"
+)
+
+type requestIDKey struct{}
+
+// requestIDHandler adds the request id of the context to every record, like a tracing handler would.
+type requestIDHandler struct {
+	slog.Handler
+}
+
+func (h requestIDHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id, ok := ctx.Value(requestIDKey{}).(string); ok {
+		r.AddAttrs(slog.String("request_id", id))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+type plainLogger struct {
+	lines []string
+}
+
+func (l *plainLogger) Warn(msg string, args ...any)  { l.lines = append(l.lines, "WARN "+msg) }
+func (l *plainLogger) Error(msg string, args ...any) { l.lines = append(l.lines, "ERROR "+msg) }
+
+func TestContextLogger(t *testing.T) {
+	fsys := &InMemoryFS{
+		Files: map[string]string{
+			"templates/index.html":   `<main>{{ child "content" }}</main>`,
+			"templates/content.html": `<div>content</div>`,
+		},
+	}
+
+	build := func() *Partial {
+		content := NewID("content", "templates/content.html").
+			WithAction(func(ctx context.Context, p *Partial, data *Data) (*Partial, error) {
+				return p, errors.New("failed")
+			})
+		return NewID("root", "templates/index.html").With(content)
+	}
+
+	t.Run("slog handler", func(t *testing.T) {
+		var buf bytes.Buffer
+		logger := slog.New(requestIDHandler{slog.NewJSONHandler(&buf, nil)})
+		svc := NewService(&Config{FS: fsys, Logger: logger})
+
+		r, _ := http.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("X-Target", "content")
+		r.Header.Set("X-Action", "save")
+
+		ctx := context.WithValue(context.Background(), requestIDKey{}, "req-1")
+		ctx = WithLogAttrs(ctx, "user", "alice")
+		_, _ = svc.NewLayout().Set(build()).RenderWithRequest(ctx, r)
+
+		var record map[string]any
+		for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+			var m map[string]any
+			if err := json.Unmarshal([]byte(line), &m); err != nil {
+				t.Fatalf("invalid log line %q: %v", line, err)
+			}
+			if m["msg"] == "error in action function" {
+				record = m
+			}
+		}
+		if record == nil {
+			t.Fatalf("expected the action error to be logged, got %s", buf.String())
+		}
+
+		expected := map[string]any{
+			"path":       "root/content",
+			"templates":  "templates/content.html",
+			"action":     "save",
+			"user":       "alice",
+			"request_id": "req-1",
+			"error":      "failed",
+		}
+		for k, v := range expected {
+			if record[k] != v {
+				t.Errorf("expected %s=%v, got %v", k, v, record[k])
+			}
+		}
+	})
+
+	t.Run("plain logger", func(t *testing.T) {
+		logger := &plainLogger{}
+		svc := NewService(&Config{FS: fsys, Logger: logger})
+
+		p := build()
+		r, _ := http.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("X-Target", "content")
+		_, _ = svc.NewLayout().Set(p).RenderWithRequest(context.Background(), r)
+		p.log(context.Background()).Info("not written")
+
+		if strings.Join(logger.lines, ",") != "ERROR error in action function" {
+			t.Errorf("unexpected log lines %v", logger.lines)
+		}
+	})
+
+	t.Run("before a request", func(t *testing.T) {
+		logger := &plainLogger{}
+		svc := NewService(&Config{FS: fsys, Logger: logger, Connector: connector.NewPartial(&connector.Config{UseURLQuery: true})})
+
+		// protected functions are reported when they are added, before the partial has a request
+		p := NewID("root", "templates/index.html")
+		svc.NewLayout().Set(p)
+		p.AddFunc("child", func() string { return "" })
+
+		if strings.Join(logger.lines, ",") != "WARN function name is protected and cannot be overwritten" {
+			t.Errorf("unexpected log lines %v", logger.lines)
+		}
+	})
+}
@@ -0,0 +1,283 @@
+package partial
+
+import (
+	"expvar"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/partial-coffee/go-partial/connector"
+)
+
+// DefaultBuckets are the upper bounds in seconds of the latency histograms of ExpvarMetrics.
+var DefaultBuckets = []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5}
+
+type (
+	// Metrics receives the measurements of the renders, set it in the Config of the service.
+	Metrics interface {
+		// ObserveRender is called after every render of a partial, err is the error of the render if any
+		ObserveRender(id string, duration time.Duration, err error)
+		// ObserveParse is called after the templates of a partial were parsed
+		ObserveParse(id string, duration time.Duration)
+		// ObserveCache is called on every template cache lookup
+		ObserveCache(id string, hit bool)
+		// ObserveActionError is called when the action of a partial returns an error
+		ObserveActionError(id string)
+		// ObserveRequest is called once per request, partial tells whether only a part of the page was requested
+		ObserveRequest(connector string, partial bool)
+	}
+
+	// ExpvarMetrics keeps the metrics in expvar variables, use Publish to expose them on /debug/vars
+	// and PrometheusHandler to expose them in the Prometheus text format.
+	ExpvarMetrics struct {
+		vars            *expvar.Map
+		renderSeconds   *histogramMap
+		parseSeconds    *histogramMap
+		renderErrors    *expvar.Map
+		cacheHits       *expvar.Map
+		cacheMisses     *expvar.Map
+		actionErrors    *expvar.Map
+		partialRequests *expvar.Map
+		fullRequests    *expvar.Map
+	}
+
+	// histogramMap holds a histogram per partial id.
+	histogramMap struct {
+		buckets []float64
+		mu      sync.RWMutex
+		m       map[string]*histogram
+	}
+
+	histogram struct {
+		mu     sync.Mutex
+		counts []uint64
+		count  uint64
+		sum    float64
+	}
+
+	noopMetrics struct{}
+)
+
+// NewExpvarMetrics returns metrics kept in expvar variables, the latency histograms use DefaultBuckets.
+func NewExpvarMetrics() *ExpvarMetrics {
+	m := &ExpvarMetrics{
+		vars:            new(expvar.Map).Init(),
+		renderSeconds:   newHistogramMap(DefaultBuckets),
+		parseSeconds:    newHistogramMap(DefaultBuckets),
+		renderErrors:    new(expvar.Map).Init(),
+		cacheHits:       new(expvar.Map).Init(),
+		cacheMisses:     new(expvar.Map).Init(),
+		actionErrors:    new(expvar.Map).Init(),
+		partialRequests: new(expvar.Map).Init(),
+		fullRequests:    new(expvar.Map).Init(),
+	}
+
+	m.vars.Set("render_seconds", m.renderSeconds)
+	m.vars.Set("parse_seconds", m.parseSeconds)
+	m.vars.Set("render_errors", m.renderErrors)
+	m.vars.Set("cache_hits", m.cacheHits)
+	m.vars.Set("cache_misses", m.cacheMisses)
+	m.vars.Set("action_errors", m.actionErrors)
+	m.vars.Set("partial_requests", m.partialRequests)
+	m.vars.Set("full_requests", m.fullRequests)
+
+	return m
+}
+
+// Publish exposes the metrics under the given name on /debug/vars, like expvar.Publish it panics when the name is taken.
+func (m *ExpvarMetrics) Publish(name string) *ExpvarMetrics {
+	expvar.Publish(name, m.vars)
+	return m
+}
+
+func (m *ExpvarMetrics) ObserveRender(id string, duration time.Duration, err error) {
+	m.renderSeconds.observe(id, duration.Seconds())
+	if err != nil {
+		m.renderErrors.Add(id, 1)
+	}
+}
+
+func (m *ExpvarMetrics) ObserveParse(id string, duration time.Duration) {
+	m.parseSeconds.observe(id, duration.Seconds())
+}
+
+func (m *ExpvarMetrics) ObserveCache(id string, hit bool) {
+	if hit {
+		m.cacheHits.Add(id, 1)
+	} else {
+		m.cacheMisses.Add(id, 1)
+	}
+}
+
+func (m *ExpvarMetrics) ObserveActionError(id string) {
+	m.actionErrors.Add(id, 1)
+}
+
+func (m *ExpvarMetrics) ObserveRequest(connector string, partial bool) {
+	if partial {
+		m.partialRequests.Add(connector, 1)
+	} else {
+		m.fullRequests.Add(connector, 1)
+	}
+}
+
+// PrometheusHandler returns a handler writing the metrics in the Prometheus text format.
+func (m *ExpvarMetrics) PrometheusHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+
+		var b strings.Builder
+		m.renderSeconds.writePrometheus(&b, "partial_render_seconds", "Render latency of a partial in seconds.")
+		m.parseSeconds.writePrometheus(&b, "partial_parse_seconds", "Template parse latency of a partial in seconds.")
+		writePrometheusCounter(&b, "partial_render_errors_total", "Failed renders of a partial.", "id", m.renderErrors)
+		writePrometheusCounter(&b, "partial_cache_hits_total", "Template cache hits of a partial.", "id", m.cacheHits)
+		writePrometheusCounter(&b, "partial_cache_misses_total", "Template cache misses of a partial.", "id", m.cacheMisses)
+		writePrometheusCounter(&b, "partial_action_errors_total", "Errors returned by the action of a partial.", "id", m.actionErrors)
+
+		b.WriteString("# HELP partial_requests_total Requests by connector and type, partial or full page.\n")
+		b.WriteString("# TYPE partial_requests_total counter\n")
+		for _, kind := range []struct {
+			name string
+			vars *expvar.Map
+		}{{"partial", m.partialRequests}, {"full", m.fullRequests}} {
+			kind.vars.Do(func(kv expvar.KeyValue) {
+				fmt.Fprintf(&b, "partial_requests_total{connector=%s,type=%q} %s\n", labelValue(kv.Key), kind.name, kv.Value)
+			})
+		}
+
+		_, _ = w.Write([]byte(b.String()))
+	})
+}
+
+func writePrometheusCounter(b *strings.Builder, name, help, label string, vars *expvar.Map) {
+	fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s counter\n", name, help, name)
+	vars.Do(func(kv expvar.KeyValue) {
+		fmt.Fprintf(b, "%s{%s=%s} %s\n", name, label, labelValue(kv.Key), kv.Value)
+	})
+}
+
+// labelValue quotes a Prometheus label value.
+func labelValue(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s) + `"`
+}
+
+func newHistogramMap(buckets []float64) *histogramMap {
+	return &histogramMap{buckets: buckets, m: make(map[string]*histogram)}
+}
+
+func (h *histogramMap) get(id string) *histogram {
+	h.mu.RLock()
+	hist, ok := h.m[id]
+	h.mu.RUnlock()
+	if ok {
+		return hist
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if hist, ok = h.m[id]; !ok {
+		hist = &histogram{counts: make([]uint64, len(h.buckets))}
+		h.m[id] = hist
+	}
+
+	return hist
+}
+
+func (h *histogramMap) observe(id string, v float64) {
+	hist := h.get(id)
+
+	hist.mu.Lock()
+	defer hist.mu.Unlock()
+
+	for i, upper := range h.buckets {
+		if v <= upper {
+			hist.counts[i]++
+		}
+	}
+	hist.count++
+	hist.sum += v
+}
+
+func (h *histogramMap) ids() []string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	ids := make([]string, 0, len(h.m))
+	for id := range h.m {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	return ids
+}
+
+// String returns the count and sum per id as JSON, it makes the histograms an expvar.Var.
+func (h *histogramMap) String() string {
+	var b strings.Builder
+	b.WriteString("{")
+	for i, id := range h.ids() {
+		hist := h.get(id)
+		hist.mu.Lock()
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		fmt.Fprintf(&b, `%q: {"count": %d, "sum": %s}`, id, hist.count, strconv.FormatFloat(hist.sum, 'g', -1, 64))
+		hist.mu.Unlock()
+	}
+	b.WriteString("}")
+
+	return b.String()
+}
+
+func (h *histogramMap) writePrometheus(b *strings.Builder, name, help string) {
+	fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s histogram\n", name, help, name)
+	for _, id := range h.ids() {
+		hist := h.get(id)
+		label := labelValue(id)
+
+		hist.mu.Lock()
+		for i, upper := range h.buckets {
+			fmt.Fprintf(b, "%s_bucket{id=%s,le=\"%s\"} %d\n", name, label, strconv.FormatFloat(upper, 'g', -1, 64), hist.counts[i])
+		}
+		fmt.Fprintf(b, "%s_bucket{id=%s,le=\"+Inf\"} %d\n", name, label, hist.count)
+		fmt.Fprintf(b, "%s_sum{id=%s} %s\n", name, label, strconv.FormatFloat(hist.sum, 'g', -1, 64))
+		fmt.Fprintf(b, "%s_count{id=%s} %d\n", name, label, hist.count)
+		hist.mu.Unlock()
+	}
+}
+
+func (noopMetrics) ObserveRender(string, time.Duration, error) {}
+func (noopMetrics) ObserveParse(string, time.Duration)         {}
+func (noopMetrics) ObserveCache(string, bool)                  {}
+func (noopMetrics) ObserveActionError(string)                  {}
+func (noopMetrics) ObserveRequest(string, bool)                {}
+
+func (p *Partial) getMetrics() Metrics {
+	if p == nil {
+		return noopMetrics{}
+	}
+
+	if p.metrics != nil {
+		return p.metrics
+	}
+
+	if p.parent != nil {
+		return p.parent.getMetrics()
+	}
+
+	return noopMetrics{}
+}
+
+// connectorName returns the name of the connector used to label the requests, e.g. "htmx".
+func connectorName(c connector.Connector) string {
+	name := fmt.Sprintf("%T", c)
+	if i := strings.LastIndexByte(name, '.'); i >= 0 {
+		name = name[i+1:]
+	}
+
+	return strings.ToLower(name)
+}
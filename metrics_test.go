@@ -0,0 +1,93 @@
+package partial
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/partial-coffee/go-partial/connector"
+)
+
+func TestExpvarMetrics(t *testing.T) {
+	fsys := &InMemoryFS{
+		Files: map[string]string{
+			"templates/index.html":   `<main>{{ child "content" }}</main>`,
+			"templates/content.html": `<div>content</div>`,
+		},
+	}
+
+	metrics := NewExpvarMetrics()
+	svc := NewService(&Config{FS: fsys, Connector: connector.NewHTMX(nil), Metrics: metrics})
+
+	build := func() *Partial {
+		content := NewID("content", "templates/content.html").
+			WithAction(func(ctx context.Context, p *Partial, data *Data) (*Partial, error) {
+				if data.Request.URL.Query().Get("fail") != "" {
+					return nil, errors.New("failed")
+				}
+				return p, nil
+			})
+		return NewID("root", "templates/index.html").With(content)
+	}
+
+	for _, target := range []string{"", "content", "content"} {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		if target != "" {
+			r.Header.Set("HX-Request", "true")
+			r.Header.Set("HX-Target", target)
+		}
+		if _, err := svc.NewLayout().Set(build()).RenderWithRequest(context.Background(), r); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	// actions only run for the target of the request
+	r := httptest.NewRequest(http.MethodGet, "/?fail=1", nil)
+	r.Header.Set("HX-Request", "true")
+	r.Header.Set("HX-Target", "content")
+	_, _ = svc.NewLayout().Set(build()).RenderWithRequest(context.Background(), r)
+
+	metrics.ObserveCache("content", true)
+	metrics.ObserveParse("widget", 3*time.Millisecond)
+
+	rec := httptest.NewRecorder()
+	metrics.PrometheusHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	out := rec.Body.String()
+
+	for _, line := range []string{
+		"# TYPE partial_render_seconds histogram",
+		`partial_render_seconds_count{id="root"} 1`,
+		`partial_render_seconds_count{id="content"} 4`,
+		`partial_render_seconds_bucket{id="content",le="+Inf"} 4`,
+		`partial_parse_seconds_count{id="content"} 3`,
+		`partial_parse_seconds_bucket{id="widget",le="0.0025"} 0`,
+		`partial_parse_seconds_bucket{id="widget",le="0.005"} 1`,
+		`partial_parse_seconds_sum{id="widget"} 0.003`,
+		`partial_render_errors_total{id="content"} 1`,
+		`partial_cache_hits_total{id="content"} 1`,
+		`partial_cache_misses_total{id="content"} 3`,
+		`partial_action_errors_total{id="content"} 1`,
+		`partial_requests_total{connector="htmx",type="partial"} 3`,
+		`partial_requests_total{connector="htmx",type="full"} 1`,
+	} {
+		if !strings.Contains(out, line+"\n") {
+			t.Errorf("expected %q in\n%s", line, out)
+		}
+	}
+
+	var vars expvar.Var = metrics.vars
+	if !strings.Contains(vars.String(), `"render_seconds": {"content": {"count": 4`) {
+		t.Errorf("expected the histograms in the expvar output, got %s", vars.String())
+	}
+}
+
+func TestLabelValue(t *testing.T) {
+	if got := labelValue("a\"b\\c\nd"); got != `"a\"b\\c\nd"` {
+		t.Errorf("unexpected label value %s", got)
+	}
+}
@@ -0,0 +1,300 @@
+package partial
+
+import (
+	"context"
+	"fmt"
+	"html/template"
+	"net/http"
+	"sync"
+
+	"github.com/partial-coffee/go-partial/connector"
+)
+
+type (
+	// renderStateKey is the context key for the request-scoped render state.
+	renderStateKey struct{}
+
+	// renderState holds the state shared by every partial rendered for a single request.
+	renderState struct {
+		mu       sync.Mutex
+		oob      []dynamicOOB
+		rendered map[string]struct{}
+		// swapTarget renders the requested target as out-of-band fragment as well, used by transports without a swap target
+		swapTarget bool
+		// status is the status code set with Data.SetStatus
+		status int
+		// partialStatus is the status code set on the deepest rendered partial with one, see Partial.SetStatus
+		partialStatus int
+		statusDepth   int
+		// header is the header of the response changed with Data.ResponseHeader
+		header http.Header
+		// partialHeader holds the headers set on rendered partials, see Partial.SetResponseHeaders
+		partialHeader http.Header
+		headerDepth   map[string]int
+		// err is the first error that fails the whole render, even when it occurs in a child rendered in place
+		err error
+	}
+
+	// OOBOption configures how an out-of-band partial is swapped into the page.
+	OOBOption func(p *Partial)
+
+	// dynamicOOB is an out-of-band partial added at request time.
+	dynamicOOB struct {
+		partial      *Partial
+		swapStrategy string
+	}
+)
+
+// OOBSwap sets the swap strategy of an out-of-band partial, see the Swap constants in the connector package.
+func OOBSwap(strategy string) OOBOption {
+	return func(p *Partial) {
+		p.oobSwap = strategy
+	}
+}
+
+// OOBTarget sets a custom target selector for an out-of-band partial, by default the element with the same id is swapped.
+func OOBTarget(selector string) OOBOption {
+	return func(p *Partial) {
+		p.oobTarget = selector
+	}
+}
+
+// withRenderState returns a context carrying a render state, reusing the one already present.
+func withRenderState(ctx context.Context) (context.Context, *renderState) {
+	if state := getRenderState(ctx); state != nil {
+		return ctx, state
+	}
+
+	state := &renderState{
+		rendered:      make(map[string]struct{}),
+		header:        make(http.Header),
+		partialHeader: make(http.Header),
+		headerDepth:   make(map[string]int),
+	}
+	return context.WithValue(ctx, renderStateKey{}, state), state
+}
+
+func getRenderState(ctx context.Context) *renderState {
+	if ctx == nil {
+		return nil
+	}
+
+	state, _ := ctx.Value(renderStateKey{}).(*renderState)
+	return state
+}
+
+// addOOB queues the partial, partials with an id that was already queued are ignored.
+func (s *renderState) addOOB(p *Partial, swapStrategy string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, o := range s.oob {
+		if o.partial.id == p.id {
+			return false
+		}
+	}
+
+	s.oob = append(s.oob, dynamicOOB{partial: p, swapStrategy: swapStrategy})
+	return true
+}
+
+// markRendered records that an out-of-band partial with the given id is part of the response.
+// It returns false if the id was already rendered.
+func (s *renderState) markRendered(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.rendered[id]; ok {
+		return false
+	}
+
+	s.rendered[id] = struct{}{}
+	return true
+}
+
+// pending returns the out-of-band partials queued after the first from, in the order they were added.
+func (s *renderState) pending(from int) []dynamicOOB {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]dynamicOOB{}, s.oob[from:]...)
+}
+
+// collect records the response settings of a rendered partial and its parents,
+// a setting of a child takes precedence over the same setting of its parents.
+func (s *renderState) collect(p *Partial) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	depth := 0
+	for q := p.parent; q != nil; q = q.parent {
+		depth++
+	}
+
+	for ; p != nil; p, depth = p.parent, depth-1 {
+		if p.status != 0 && (s.partialStatus == 0 || depth >= s.statusDepth) {
+			s.partialStatus, s.statusDepth = p.status, depth
+		}
+
+		for k, v := range p.responseHeader {
+			k = http.CanonicalHeaderKey(k)
+			if d, ok := s.headerDepth[k]; !ok || depth >= d {
+				s.partialHeader[k] = append([]string{}, v...)
+				s.headerDepth[k] = depth
+			}
+		}
+	}
+}
+
+func (s *renderState) setStatus(code int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.status = code
+}
+
+// fail records an error that fails the render of the request, only the first error is kept.
+func (s *renderState) fail(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.err == nil {
+		s.err = err
+	}
+}
+
+// failure returns the error recorded with fail.
+func (s *renderState) failure() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.err
+}
+
+// AddOOB adds an out-of-band partial to the current response.
+// It can be called from actions to update parts of the page that are not part of the requested target,
+// for example a cart badge or a toast. The partials are rendered after the statically registered OOB children,
+// in the order they were added; a partial with an id that is already part of the response is skipped.
+// An empty swap strategy keeps the strategy configured on the partial.
+// Dynamic OOB partials are only rendered for partial requests.
+func (d *Data) AddOOB(p *Partial, swapStrategy string) {
+	if d == nil || p == nil {
+		return
+	}
+
+	state := getRenderState(d.Ctx)
+	if state == nil {
+		return
+	}
+
+	if p.parent == nil && d.partial != nil {
+		p.parent = d.partial
+	}
+
+	state.addOOB(p, swapStrategy)
+}
+
+// renderDynamicOOB renders the out-of-band partials that were added during the request.
+func (p *Partial) renderDynamicOOB(ctx context.Context, r *http.Request) (template.HTML, error) {
+	state := getRenderState(ctx)
+	if state == nil {
+		return "", nil
+	}
+
+	var out template.HTML
+	// rendering an OOB partial can queue more partials, so keep going until nothing new was added
+	for done := 0; ; {
+		queued := state.pending(done)
+		if len(queued) == 0 {
+			return out, nil
+		}
+		done += len(queued)
+
+		for _, o := range queued {
+			html, err := p.renderQueuedOOB(ctx, r, state, o)
+			if err != nil {
+				return "", err
+			}
+			out += html
+		}
+	}
+}
+
+// renderQueuedOOB renders a single out-of-band partial added during the request, unless its id was already rendered.
+func (p *Partial) renderQueuedOOB(ctx context.Context, r *http.Request, state *renderState, o dynamicOOB) (template.HTML, error) {
+	if !state.markRendered(o.partial.id) {
+		return "", nil
+	}
+
+	clone := o.partial.clone()
+	if clone.parent == nil {
+		clone.parent = p
+	}
+	clone.swapOOB = true
+	if o.swapStrategy != "" {
+		clone.oobSwap = o.swapStrategy
+	}
+	if clone.oobSwap == "" && state.swapTarget {
+		clone.oobSwap = connector.SwapOuterHTML
+	}
+
+	html, err := clone.renderOOB(ctx, r)
+	if err != nil {
+		return "", fmt.Errorf("error rendering dynamic OOB partial '%s': %w", o.partial.id, err)
+	}
+
+	return html, nil
+}
+
+// renderOOB renders the partial as out-of-band fragment in the dialect of the connector.
+func (p *Partial) renderOOB(ctx context.Context, r *http.Request) (template.HTML, error) {
+	renderer := p.getOOBRenderer()
+
+	if p.oobSwap == connector.SwapDelete {
+		return template.HTML(renderer.RenderOOB(p.id, p.oobSwap, p.oobTarget, "")), nil
+	}
+
+	html, err := p.renderSelf(ctx, r)
+	if err != nil {
+		return "", err
+	}
+
+	if p.oobSwap == "" && p.oobTarget == "" {
+		return html, nil
+	}
+
+	return template.HTML(renderer.RenderOOB(p.id, p.oobSwap, p.oobTarget, string(html))), nil
+}
+
+// getOOBRenderer returns the out-of-band dialect of the connector, falling back to the default partial connector.
+func (p *Partial) getOOBRenderer() connector.OOBRenderer {
+	if renderer, ok := p.getConnector().(connector.OOBRenderer); ok {
+		return renderer
+	}
+
+	return connector.NewPartial(nil).(connector.OOBRenderer)
+}
+
+// addOOBFunc queues a child of the partial, or of one of its ancestors, as out-of-band partial for the response.
+func addOOBFunc(p *Partial, data *Data) func(id string, swapStrategy ...string) template.HTML {
+	return func(id string, swapStrategy ...string) template.HTML {
+		var child *Partial
+		for ancestor := p; ancestor != nil && child == nil; ancestor = ancestor.parent {
+			child = ancestor.recursiveChildLookup(id, make(map[string]bool))
+		}
+
+		if child == nil {
+			p.getLogger().Warn("OOB partial not found", "id", id, "parent", p.id)
+			return ""
+		}
+
+		var swap string
+		if len(swapStrategy) > 0 {
+			swap = swapStrategy[0]
+		}
+
+		data.AddOOB(child, swap)
+
+		return ""
+	}
+}
@@ -0,0 +1,228 @@
+package partial
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/partial-coffee/go-partial/connector"
+)
+
+func TestDynamicOOB(t *testing.T) {
+	fsys := &InMemoryFS{
+		Files: map[string]string{
+			"templates/index.html":   `<html><body>{{ child "content" }}{{ child "badge" }}{{ child "footer" }}</body></html>`,
+			"templates/content.html": `<div>{{ .Data.Text }}</div>{{ addOOB "badge" }}`,
+			"templates/badge.html":   `<span {{ oobSwapIfEnabled "true" }} id="badge">{{ .Data.Count }}</span>`,
+			"templates/toast.html":   `<div {{ oobSwapIfEnabled "true" }} id="toasts">{{ .Data.Message }}</div>`,
+			"templates/footer.html":  `<footer {{ oobSwapIfEnabled "true" }}>footer</footer>`,
+		},
+	}
+
+	svc := NewService(&Config{FS: fsys})
+
+	var handleRequest = func(w http.ResponseWriter, r *http.Request) {
+		content := New("templates/content.html").ID("content").SetData(map[string]any{"Text": "cart updated"})
+		content.WithAction(func(ctx context.Context, p *Partial, data *Data) (*Partial, error) {
+			toast := New("templates/toast.html").ID("toast").SetData(map[string]any{"Message": "saved"})
+			data.AddOOB(toast, "beforeend")
+			data.AddOOB(toast, "innerHTML")
+			data.AddOOB(New("templates/footer.html").ID("footer"), "")
+			return p, nil
+		})
+
+		p := New("templates/index.html").ID("root")
+		p.With(content)
+		p.With(New("templates/badge.html").ID("badge").SetData(map[string]any{"Count": 3}))
+		p.WithOOB(New("templates/footer.html").ID("footer"))
+
+		out, err := svc.NewLayout().Set(p).RenderWithRequest(r.Context(), r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		_, _ = w.Write([]byte(out))
+	}
+
+	t.Run("partial", func(t *testing.T) {
+		request, _ := http.NewRequest(http.MethodPost, "/", nil)
+		request.Header.Set("X-Target", "content")
+		response := httptest.NewRecorder()
+
+		handleRequest(response, request)
+
+		expected := `<div>cart updated</div>` +
+			`<footer x-swap-oob="true">footer</footer>` +
+			`<div x-swap-oob="beforeend" id="toasts">saved</div>` +
+			`<span x-swap-oob="true" id="badge">3</span>`
+		if response.Body.String() != expected {
+			t.Errorf("expected %s, got %s", expected, response.Body.String())
+		}
+	})
+
+	t.Run("full page", func(t *testing.T) {
+		request, _ := http.NewRequest(http.MethodGet, "/", nil)
+		response := httptest.NewRecorder()
+
+		handleRequest(response, request)
+
+		expected := `<html><body><div>cart updated</div><span  id="badge">3</span><footer >footer</footer></body></html>`
+		if response.Body.String() != expected {
+			t.Errorf("expected %s, got %s", expected, response.Body.String())
+		}
+	})
+}
+
+func TestDynamicOOBQueuedByOOB(t *testing.T) {
+	fsys := &InMemoryFS{
+		Files: map[string]string{
+			"templates/content.html": `<div>content</div>`,
+			"templates/step.html":    `<p id="{{ .Data.N }}">{{ .Data.N }}</p>`,
+		},
+	}
+
+	svc := NewService(&Config{FS: fsys})
+
+	// every step queues the next one while it renders as OOB partial
+	var step func(n int) *Partial
+	step = func(n int) *Partial {
+		return New("templates/step.html").ID(fmt.Sprintf("step-%d", n)).AddData("N", n).BeforeRender(func(ctx context.Context, p *Partial, data *Data) error {
+			if n < 3 {
+				data.AddOOB(step(n+1), "")
+				data.AddOOB(step(1), "")
+			}
+			return nil
+		})
+	}
+
+	content := New("templates/content.html").ID("content").WithAction(func(ctx context.Context, p *Partial, data *Data) (*Partial, error) {
+		data.AddOOB(step(1), "")
+		return p, nil
+	})
+
+	request, _ := http.NewRequest(http.MethodGet, "/", nil)
+	request.Header.Set("X-Target", "content")
+	out, err := svc.NewLayout().Set(New("templates/content.html").ID("root").With(content)).RenderWithRequest(context.Background(), request)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := `<div>content</div><p id="1">1</p><p id="2">2</p><p id="3">3</p>`
+	if string(out) != expected {
+		t.Errorf("expected %s, got %s", expected, out)
+	}
+}
+
+func TestOOBSwapStrategies(t *testing.T) {
+	fsys := &InMemoryFS{
+		Files: map[string]string{
+			"templates/index.html":   `{{ child "content" }}`,
+			"templates/content.html": `<div>content</div>`,
+			"templates/row.html":     `<tr {{ oobSwapIfEnabled "true" }}><td>row</td></tr>`,
+			"templates/notice.html":  `<p {{ oobSwapIfEnabled "true" }}>notice</p>`,
+			"templates/gone.html":    `<div id="gone">gone</div>`,
+			"templates/badge.html":   `<span id="badge" {{ oobSwapIfEnabled "true" }}>2</span>`,
+		},
+	}
+
+	newPage := func() *Partial {
+		p := New("templates/index.html").ID("root")
+		p.With(New("templates/content.html").ID("content"))
+		p.WithOOB(New("templates/row.html").ID("row"), OOBSwap(connector.SwapBeforeEnd), OOBTarget("#rows"))
+		p.WithOOB(New("templates/notice.html").ID("notice"), OOBSwap(connector.SwapAfterBegin))
+		p.WithOOB(New("templates/gone.html").ID("gone"), OOBSwap(connector.SwapDelete))
+		p.WithOOB(New("templates/badge.html").ID("badge"), OOBSwap(connector.SwapOuterHTML))
+		return p
+	}
+
+	testCases := []struct {
+		name      string
+		connector connector.Connector
+		header    map[string]string
+		expected  string
+	}{
+		{
+			name:      "htmx",
+			connector: connector.NewHTMX(nil),
+			header:    map[string]string{"HX-Request": "true", "HX-Target": "content"},
+			expected: `<div>content</div>` +
+				`<tr hx-swap-oob="beforeend:#rows"><td>row</td></tr>` +
+				`<p hx-swap-oob="afterbegin">notice</p>` +
+				`<div id="gone" hx-swap-oob="delete"></div>` +
+				`<span id="badge" hx-swap-oob="outerHTML">2</span>`,
+		},
+		{
+			name:      "turbo",
+			connector: connector.NewTurbo(nil),
+			header:    map[string]string{"Turbo-Frame": "content"},
+			expected: `<div>content</div>` +
+				`<turbo-stream action="append" targets="#rows"><template><tr ><td>row</td></tr></template></turbo-stream>` +
+				`<turbo-stream action="prepend" target="notice"><template><p >notice</p></template></turbo-stream>` +
+				`<turbo-stream action="remove" target="gone"></turbo-stream>` +
+				`<turbo-stream action="replace" target="badge"><template><span id="badge" >2</span></template></turbo-stream>`,
+		},
+		{
+			name:      "stimulus",
+			connector: connector.NewStimulus(nil),
+			header:    map[string]string{"X-Stimulus-Target": "content"},
+			expected: `<div>content</div>` +
+				`<turbo-stream action="append" targets="#rows"><template><tr ><td>row</td></tr></template></turbo-stream>` +
+				`<turbo-stream action="prepend" target="notice"><template><p >notice</p></template></turbo-stream>` +
+				`<turbo-stream action="remove" target="gone"></turbo-stream>` +
+				`<turbo-stream action="replace" target="badge"><template><span id="badge" >2</span></template></turbo-stream>`,
+		},
+		{
+			name:      "unpoly",
+			connector: connector.NewUnpoly(nil),
+			header:    map[string]string{"X-Up-Target": "content"},
+			expected: `<div>content</div>` +
+				`<tr x-swap-oob="beforeend:#rows"><td>row</td></tr>` +
+				`<p x-swap-oob="afterbegin">notice</p>` +
+				`<div id="gone" x-swap-oob="delete"></div>` +
+				`<span id="badge" up-hungry>2</span>`,
+		},
+		{
+			name:      "alpine-ajax",
+			connector: connector.NewAlpineAjax(nil),
+			header:    map[string]string{"X-Alpine-Target": "content"},
+			expected: `<div>content</div>` +
+				`<tr x-swap-oob="beforeend:#rows"><td>row</td></tr>` +
+				`<p x-sync x-merge="prepend">notice</p>` +
+				`<div id="gone" x-swap-oob="delete"></div>` +
+				`<span id="badge" x-sync>2</span>`,
+		},
+		{
+			name:      "vue falls back to x-swap-oob",
+			connector: connector.NewVue(nil),
+			header:    map[string]string{"X-Vue-Target": "content"},
+			expected: `<div>content</div>` +
+				`<tr x-swap-oob="beforeend:#rows"><td>row</td></tr>` +
+				`<p x-swap-oob="afterbegin">notice</p>` +
+				`<div id="gone" x-swap-oob="delete"></div>` +
+				`<span id="badge" x-swap-oob="outerHTML">2</span>`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			svc := NewService(&Config{FS: fsys, Connector: tc.connector})
+
+			request, _ := http.NewRequest(http.MethodGet, "/", nil)
+			for k, v := range tc.header {
+				request.Header.Set(k, v)
+			}
+
+			out, err := svc.NewLayout().Set(newPage()).RenderWithRequest(context.Background(), request)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if string(out) != tc.expected {
+				t.Errorf("expected %s, got %s", tc.expected, out)
+			}
+		})
+	}
+}
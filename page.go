@@ -0,0 +1,162 @@
+package partial
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+
+	"github.com/partial-coffee/go-partial/connector"
+)
+
+// patternWildcard matches the wildcards of a ServeMux pattern, e.g. "{id}" and "{path...}", but not "{$}".
+var patternWildcard = regexp.MustCompile(`\{([^{}.$]+)(?:\.\.\.)?\}`)
+
+// Page serves the partial built for every request, it is registered with a ServeMux pattern.
+// The path values of the pattern are added to the data of the partial, e.g. {id} is available as .Data.id.
+type Page struct {
+	service *Service
+	pattern string
+	params  []string
+	build   func(r *http.Request) *Partial
+	wrapper func(r *http.Request) *Partial
+	actions map[string]func(ctx context.Context, p *Partial, data *Data) (*Partial, error)
+}
+
+// NewPage returns a page serving the partial returned by build, register it on a ServeMux with the same pattern.
+// When build returns nil the page responds with 404 Not Found. Build may return the same tree for every request,
+// the page renders a copy of it.
+func (svc *Service) NewPage(pattern string, build func(r *http.Request) *Partial) *Page {
+	var params []string
+	for _, m := range patternWildcard.FindAllStringSubmatch(pattern, -1) {
+		params = append(params, m[1])
+	}
+
+	return &Page{
+		service: svc,
+		pattern: pattern,
+		params:  params,
+		build:   build,
+		actions: make(map[string]func(ctx context.Context, p *Partial, data *Data) (*Partial, error)),
+	}
+}
+
+// Handle registers a page for the pattern on the ServeMux of the service, see ServeHTTP.
+// The pattern follows the rules of http.ServeMux, e.g. "GET /items/{id}".
+func (svc *Service) Handle(pattern string, build func(r *http.Request) *Partial) *Page {
+	page := svc.NewPage(pattern, build)
+	svc.mux.Handle(pattern, page)
+	return page
+}
+
+// ServeHTTP serves the pages registered with Handle.
+func (svc *Service) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	svc.mux.ServeHTTP(w, r)
+}
+
+// Wrap sets the wrapper the page is rendered in, partial requests render without it.
+func (pg *Page) Wrap(wrapper func(r *http.Request) *Partial) *Page {
+	pg.wrapper = wrapper
+	return pg
+}
+
+// Action sets the action run for requests with the method, e.g. http.MethodPost.
+// It runs as the action of the requested target, or of the page when the request has no target.
+// When the partial already has an action, e.g. the guards of a definition, that action runs first
+// and an error returned by it stops the page action.
+func (pg *Page) Action(method string, action func(ctx context.Context, p *Partial, data *Data) (*Partial, error)) *Page {
+	pg.actions[method] = action
+	return pg
+}
+
+// Pattern returns the pattern of the page.
+func (pg *Page) Pattern() string {
+	return pg.pattern
+}
+
+func (pg *Page) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p := pg.build(r)
+	if p == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	// the path values and the action only apply to this request
+	p = copyTree(p, p.parent)
+
+	for _, name := range pg.params {
+		p.AddData(name, r.PathValue(name))
+	}
+
+	if action, ok := pg.actions[r.Method]; ok {
+		target := p
+		if id := pg.connector().GetTargetValue(r); id != "" {
+			walkPartials(p, func(candidate *Partial) {
+				if candidate.id == id && target == p {
+					target = candidate
+				}
+			})
+		}
+		target.WithAction(chainActions(target.action, action))
+	}
+
+	layout := pg.service.NewLayout()
+
+	layout.Set(p)
+	if pg.wrapper != nil {
+		if wrapper := pg.wrapper(r); wrapper != nil {
+			layout.Wrap(wrapper)
+		}
+	}
+
+	// the error response is written by the partial
+	_ = layout.WriteWithRequest(r.Context(), w, r)
+}
+
+// chainActions returns an action running first and then next on the partial returned by first.
+func chainActions(first, next func(ctx context.Context, p *Partial, data *Data) (*Partial, error)) func(ctx context.Context, p *Partial, data *Data) (*Partial, error) {
+	if first == nil {
+		return next
+	}
+
+	return func(ctx context.Context, p *Partial, data *Data) (*Partial, error) {
+		result, err := first(ctx, p, data)
+		if err != nil {
+			return result, err
+		}
+		if result == nil {
+			result = p
+		}
+
+		return next(ctx, result, data)
+	}
+}
+
+// copyTree copies the partial and the partials below it, children and selected partials alike.
+func copyTree(p, parent *Partial) *Partial {
+	c := p.cloneWithActions()
+	c.parent = parent
+
+	for id, child := range c.children {
+		c.children[id] = copyTree(child, c)
+	}
+
+	if p.selection != nil {
+		c.selection = &Selection{Default: p.selection.Default, Partials: make(map[string]*Partial, len(p.selection.Partials))}
+		for key, selected := range p.selection.Partials {
+			if selected != nil {
+				selected = copyTree(selected, c)
+			}
+			c.selection.Partials[key] = selected
+		}
+	}
+
+	return c
+}
+
+func (pg *Page) connector() connector.Connector {
+	if pg.service.connector != nil {
+		return pg.service.connector
+	}
+
+	return connector.NewPartial(nil)
+}
@@ -0,0 +1,157 @@
+package partial
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/partial-coffee/go-partial/connector"
+)
+
+func TestServiceHandle(t *testing.T) {
+	fsys := &InMemoryFS{
+		Files: map[string]string{
+			"templates/layout.html":  `<html>{{ child "content" }}</html>`,
+			"templates/item.html":    `<main>item {{ .Data.id }}: {{ child "form" }}</main>`,
+			"templates/form.html":    `<form>{{ .Data.Status }}</form>`,
+			"templates/files.html":   `<p>{{ .Data.path }}</p>`,
+			"templates/broken.html":  `{{ index .Data.Items 3 }}`,
+			"templates/counter.html": `<b>{{ .Data.State.Count }} {{ .Data.Status }}</b>`,
+		},
+	}
+
+	svc := NewService(&Config{FS: fsys, Connector: connector.NewHTMX(nil)})
+
+	svc.Handle("/items/{id}", func(r *http.Request) *Partial {
+		if r.PathValue("id") == "0" {
+			return nil
+		}
+		form := NewID("form", "templates/form.html").SetData(map[string]any{"Status": "new"})
+		return NewID("content", "templates/item.html").With(form)
+	}).
+		Wrap(func(r *http.Request) *Partial { return NewID("layout", "templates/layout.html") }).
+		Action(http.MethodPost, func(ctx context.Context, p *Partial, data *Data) (*Partial, error) {
+			data.Data["Status"] = "saved " + data.Request.PathValue("id")
+			return p, nil
+		})
+
+	svc.Handle("GET /files/{path...}", func(r *http.Request) *Partial {
+		return NewID("files", "templates/files.html")
+	})
+
+	svc.Handle("/broken", func(r *http.Request) *Partial {
+		return NewID("broken", "templates/broken.html").WithAction(func(ctx context.Context, p *Partial, data *Data) (*Partial, error) {
+			return p, errors.New("failed")
+		})
+	})
+
+	svc.Handle("/guarded", func(r *http.Request) *Partial {
+		form := NewID("form", "templates/form.html").WithAction(func(ctx context.Context, p *Partial, data *Data) (*Partial, error) {
+			if data.Request.Header.Get("X-Deny") != "" {
+				return p, errors.New("denied")
+			}
+			data.Data["Status"] = "checked"
+			return p, nil
+		})
+		return NewID("content", "templates/item.html").With(form)
+	}).
+		Action(http.MethodPost, func(ctx context.Context, p *Partial, data *Data) (*Partial, error) {
+			data.Data["Status"] = data.Data["Status"].(string) + ", saved"
+			return p, nil
+		})
+
+	svc.Handle("/counter", func(r *http.Request) *Partial {
+		counter := NewComponent(NewID("counter", "templates/counter.html"), counterState{Count: 3})
+		return NewID("content", "templates/item.html").With(counter.Partial())
+	}).
+		Action(http.MethodPost, func(ctx context.Context, p *Partial, data *Data) (*Partial, error) {
+			data.Data["Status"] = "saved"
+			return p, nil
+		})
+
+	tests := []struct {
+		name     string
+		method   string
+		target   string
+		headers  map[string]string
+		status   int
+		expected string
+	}{
+		{name: "full page", method: http.MethodGet, target: "/items/7", status: http.StatusOK, expected: `<html><main>item 7: <form>new</form></main></html>`},
+		{name: "partial request", method: http.MethodGet, target: "/items/7", headers: map[string]string{"HX-Request": "true", "HX-Target": "content"}, status: http.StatusOK, expected: `<main>item 7: <form>new</form></main>`},
+		{name: "method action on the page", method: http.MethodPost, target: "/items/7", status: http.StatusOK, expected: `<html><main>item 7: <form>new</form></main></html>`},
+		{name: "method action on the target", method: http.MethodPost, target: "/items/7", headers: map[string]string{"HX-Request": "true", "HX-Target": "form"}, status: http.StatusOK, expected: `<form>saved 7</form>`},
+		{name: "method action after the action of the target", method: http.MethodPost, target: "/guarded", headers: map[string]string{"HX-Request": "true", "HX-Target": "form"}, status: http.StatusOK, expected: `<form>checked, saved</form>`},
+		{name: "method action stopped by the action of the target", method: http.MethodPost, target: "/guarded", headers: map[string]string{"HX-Request": "true", "HX-Target": "form", "X-Deny": "1"}, status: http.StatusInternalServerError},
+		{name: "method action on a component", method: http.MethodPost, target: "/counter", headers: map[string]string{"HX-Request": "true", "HX-Target": "counter"}, status: http.StatusOK, expected: `<b>3 saved</b>`},
+		{name: "not found", method: http.MethodGet, target: "/items/0", status: http.StatusNotFound, expected: "404 page not found\n"},
+		{name: "rest wildcard without wrapper", method: http.MethodGet, target: "/files/a/b.txt", status: http.StatusOK, expected: `<p>a/b.txt</p>`},
+		{name: "method not allowed", method: http.MethodPost, target: "/files/a", status: http.StatusMethodNotAllowed},
+		{name: "error", method: http.MethodGet, target: "/broken", status: http.StatusInternalServerError, expected: "Internal Server Error\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, tt.target, nil)
+			for k, v := range tt.headers {
+				r.Header.Set(k, v)
+			}
+
+			rec := httptest.NewRecorder()
+			svc.ServeHTTP(rec, r)
+
+			if rec.Code != tt.status {
+				t.Errorf("expected status %d, got %d", tt.status, rec.Code)
+			}
+			if tt.expected != "" && rec.Body.String() != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, rec.Body.String())
+			}
+		})
+	}
+
+	t.Run("reused tree", func(t *testing.T) {
+		calls := 0
+		tree := NewID("content", "templates/item.html").With(NewID("form", "templates/form.html"))
+		page := svc.NewPage("POST /cached/{id}", func(r *http.Request) *Partial { return tree }).
+			Action(http.MethodPost, func(ctx context.Context, p *Partial, data *Data) (*Partial, error) {
+				calls++
+				return p, nil
+			})
+
+		for i, id := range []string{"1", "2", "3"} {
+			rec := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPost, "/cached/"+id, nil)
+			r.SetPathValue("id", id)
+			page.ServeHTTP(rec, r)
+
+			if calls != i+1 {
+				t.Errorf("request %d: expected the action to run once per request, ran %d times", i+1, calls)
+			}
+			if !strings.HasPrefix(rec.Body.String(), "<main>item "+id+": ") {
+				t.Errorf("request %d: unexpected body %q", i+1, rec.Body.String())
+			}
+		}
+
+		if tree.action != nil || len(tree.data) != 0 {
+			t.Errorf("expected the tree returned by build to be unchanged")
+		}
+	})
+
+	t.Run("own mux", func(t *testing.T) {
+		page := svc.NewPage("GET /own/{id}", func(r *http.Request) *Partial {
+			return NewID("files", "templates/item.html").With(NewID("form", "templates/form.html"))
+		})
+
+		mux := http.NewServeMux()
+		mux.Handle(page.Pattern(), page)
+
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/own/3", nil))
+		if !strings.HasPrefix(rec.Body.String(), `<main>item 3: `) {
+			t.Errorf("unexpected body %q", rec.Body.String())
+		}
+	})
+}
@@ -15,6 +15,7 @@ import (
 	"reflect"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/partial-coffee/go-partial/connector"
 )
@@ -26,11 +27,24 @@ var (
 	mutexCache = sync.Map{}
 	// protectedFunctionNames is a set of function names that are protected from being overridden
 	protectedFunctionNames = map[string]struct{}{
+		"addOOB":                     {},
 		"child":                      {},
+		"children":                   {},
+		"childrenOf":                 {},
+		"componentState":             {},
+		"componentStateValue":        {},
 		"context":                    {},
+		"devOverlay":                 {},
+		"listItems":                  {},
+		"listVersion":                {},
 		"selection":                  {},
 		"oobSwapEnabled":             {},
 		"oobSwapIfEnabled":           {},
+		"route":                      {},
+		"withQuery":                  {},
+		"withoutQuery":               {},
+		"toggleQuery":                {},
+		"queryValues":                {},
 		"url":                        {},
 		"urlIs":                      {},
 		"urlStarts":                  {},
@@ -54,6 +68,8 @@ type (
 		parent            *Partial
 		request           *http.Request
 		swapOOB           bool
+		oobSwap           string
+		oobTarget         string
 		alwaysSwapOOB     bool
 		fs                fs.FS
 		logger            Logger
@@ -63,14 +79,30 @@ type (
 		combinedFunctions template.FuncMap
 		basePath          string
 		data              map[string]any
+		props             any
+		dataType          reflect.Type
 		layoutData        map[string]any
 		globalData        map[string]any
 		serviceData       map[string]any
-		responseHeaders   map[string]string
+		responseHeader    http.Header
+		status            int
 		mu                sync.RWMutex
 		children          map[string]*Partial
+		childOrder        []string
 		oobChildren       map[string]struct{}
+		oobOrder          []string
+		slots             map[string][]string
 		selection         *Selection
+		list              *list
+		stateKeys         [][]byte
+		dev               *devOptions
+		hooks             renderHooks
+		serviceHooks      *renderHooks
+		tracer            Tracer
+		metrics           Metrics
+		fixtures          []Fixture
+		routes            map[string]string
+		component         func(ctx context.Context, p *Partial, data *Data) error
 		templateAction    func(ctx context.Context, p *Partial, data *Data) (*Partial, error)
 		action            func(ctx context.Context, p *Partial, data *Data) (*Partial, error)
 	}
@@ -90,6 +122,8 @@ type (
 		Request *http.Request
 		// Data contains the data specific to this partial
 		Data map[string]any
+		// Props contains the typed props of the partial, see NewTyped
+		Props any
 		// Service contains global data available to all partials
 		Service map[string]any
 		// LayoutData contains data specific to the service
@@ -102,6 +136,9 @@ type (
 		Csrf CsrfToken
 		// BasePath is the base path of the partial
 		BasePath string
+
+		// partial is the partial being rendered
+		partial *Partial
 	}
 
 	// GlobalData represents the global data available to all partials.
@@ -120,6 +157,7 @@ func New(templates ...string) *Partial {
 		serviceData:       make(map[string]any),
 		children:          make(map[string]*Partial),
 		oobChildren:       make(map[string]struct{}),
+		slots:             make(map[string][]string),
 		fs:                os.DirFS("./"),
 	}
 }
@@ -148,7 +186,10 @@ func (p *Partial) Reset() *Partial {
 	p.globalData = make(map[string]any)
 	p.serviceData = make(map[string]any)
 	p.children = make(map[string]*Partial)
+	p.childOrder = nil
 	p.oobChildren = make(map[string]struct{})
+	p.oobOrder = nil
+	p.slots = make(map[string][]string)
 
 	return p
 }
@@ -179,26 +220,76 @@ func (p *Partial) AddData(key string, value any) *Partial {
 	return p
 }
 
+// SetResponseHeaders sets headers of every response the partial is rendered in, the headers are set on the parents as well.
+// The headers of the current request are changed from an action with Data.ResponseHeader, which takes precedence.
 func (p *Partial) SetResponseHeaders(headers map[string]string) *Partial {
 	// in case we are working with nested partials, we need to set the headers on the parent
 	if p.parent != nil {
 		p.parent.SetResponseHeaders(headers)
 	}
 
-	p.responseHeaders = headers
+	if p.responseHeader == nil {
+		p.responseHeader = make(http.Header)
+	}
+
+	// the keys are kept as given, GetResponseHeaders returns them unchanged
+	for k, v := range headers {
+		p.responseHeader[k] = []string{v}
+	}
+
 	return p
 }
 
+// GetResponseHeaders returns the headers set on the partial or its parents.
 func (p *Partial) GetResponseHeaders() map[string]string {
 	if p == nil {
 		return nil
 	}
 
-	if p.responseHeaders == nil {
+	if p.responseHeader == nil {
 		return p.parent.GetResponseHeaders()
 	}
 
-	return p.responseHeaders
+	headers := make(map[string]string, len(p.responseHeader))
+	for k, v := range p.responseHeader {
+		if len(v) > 0 {
+			headers[k] = v[0]
+		}
+	}
+
+	return headers
+}
+
+// SetStatus sets the status code of every response the partial is rendered in, e.g. http.StatusCreated.
+// The status of the current request is set from an action with Data.SetStatus, which takes precedence.
+func (p *Partial) SetStatus(code int) *Partial {
+	p.status = code
+	return p
+}
+
+// GetStatus returns the status code set on the partial or its parents, 0 when none was set.
+func (p *Partial) GetStatus() int {
+	if p == nil {
+		return 0
+	}
+
+	if p.status == 0 {
+		return p.parent.GetStatus()
+	}
+
+	return p.status
+}
+
+// SetStatus sets the status code of the current response, e.g. http.StatusUnprocessableEntity
+// when the submitted form is invalid. A child can set the status of the whole response.
+func (d *Data) SetStatus(code int) {
+	if d == nil {
+		return
+	}
+
+	if state := getRenderState(d.Ctx); state != nil {
+		state.setStatus(code)
+	}
 }
 
 func (p *Partial) GetBasePath() string {
@@ -243,7 +334,7 @@ func (p *Partial) SetAlwaysSwapOOB(alwaysSwapOOB bool) *Partial {
 // AddFunc adds a function to the partial.
 func (p *Partial) AddFunc(name string, fn interface{}) *Partial {
 	if _, ok := protectedFunctionNames[name]; ok {
-		p.getLogger().Warn("function name is protected and cannot be overwritten", "function", name)
+		p.log(context.Background()).Warn("function name is protected and cannot be overwritten", "function", name)
 		return p
 	}
 
@@ -261,7 +352,7 @@ func (p *Partial) MergeFuncMap(funcMap template.FuncMap) {
 
 	for k, v := range funcMap {
 		if _, ok := protectedFunctionNames[k]; ok {
-			p.getLogger().Warn("function name is protected and cannot be overwritten", "function", k)
+			p.log(context.Background()).Warn("function name is protected and cannot be overwritten", "function", k)
 			continue
 		}
 
@@ -307,16 +398,44 @@ func (p *Partial) AddTemplate(template string) *Partial {
 }
 
 // With adds a child partial to the partial.
+// Children keep the order in which they were added; adding a child with an existing id replaces it in place.
 func (p *Partial) With(child *Partial) *Partial {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
+	p.addChild(child)
+
+	return p
+}
+
+// WithIn adds a child partial to the named slot of the partial.
+// All children of a slot can be rendered in order with the childrenOf template function.
+func (p *Partial) WithIn(slot string, child *Partial) *Partial {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.addChild(child)
+
+	if p.slots == nil {
+		p.slots = make(map[string][]string)
+	}
+	if !containsString(p.slots[slot], child.id) {
+		p.slots[slot] = append(p.slots[slot], child.id)
+	}
+
+	return p
+}
+
+// addChild registers the child and keeps track of the insertion order, the caller must hold the lock.
+func (p *Partial) addChild(child *Partial) {
+	if _, ok := p.children[child.id]; !ok {
+		p.childOrder = append(p.childOrder, child.id)
+	}
+
 	p.children[child.id] = child
 	p.children[child.id].globalData = p.globalData
 	p.children[child.id].serviceData = p.serviceData
 	p.children[child.id].parent = p
-
-	return p
 }
 
 // WithAction adds callback action to the partial, which can do some logic and return a partial to render.
@@ -350,9 +469,17 @@ func (p *Partial) SetParent(parent *Partial) *Partial {
 }
 
 // WithOOB adds an out-of-band child partial to the partial.
-func (p *Partial) WithOOB(child *Partial) *Partial {
+// The options configure how the child is swapped into the page, see OOBSwap and OOBTarget.
+func (p *Partial) WithOOB(child *Partial, opts ...OOBOption) *Partial {
+	for _, opt := range opts {
+		opt(child)
+	}
+
 	p.With(child)
 	p.mu.Lock()
+	if _, ok := p.oobChildren[child.id]; !ok {
+		p.oobOrder = append(p.oobOrder, child.id)
+	}
 	p.oobChildren[child.id] = struct{}{}
 	p.mu.Unlock()
 
@@ -360,9 +487,17 @@ func (p *Partial) WithOOB(child *Partial) *Partial {
 }
 
 // RenderWithRequest renders the partial with the given http.Request.
+// The status code and headers set while rendering are returned by RenderResponse.
 func (p *Partial) RenderWithRequest(ctx context.Context, r *http.Request) (template.HTML, error) {
+	out, _, err := p.RenderResponse(ctx, r)
+	return out, err
+}
+
+// RenderResponse renders the partial with the given http.Request like RenderWithRequest and returns the status code
+// and the headers set while rendering, see Data.SetStatus, Data.ResponseHeader and SetResponseHeaders.
+func (p *Partial) RenderResponse(ctx context.Context, r *http.Request) (template.HTML, *Response, error) {
 	if p == nil {
-		return "", errors.New("partial is not initialized")
+		return "", nil, errors.New("partial is not initialized")
 	}
 
 	p.request = r
@@ -370,35 +505,64 @@ func (p *Partial) RenderWithRequest(ctx context.Context, r *http.Request) (templ
 		p.connector = connector.NewPartial(nil)
 	}
 
-	if p.connector.RenderPartial(r) {
-		return p.renderWithTarget(ctx, r)
+	ctx, state := withRenderState(ctx)
+
+	out, err := p.renderRequest(ctx, r)
+	if err == nil {
+		// a failed guard fails the request, also when the guarded partial was rendered in place as a child
+		err = state.failure()
+	}
+	if err != nil {
+		return "", nil, err
+	}
+
+	return out, state.response(), nil
+}
+
+// renderRequest renders the target of a partial request, or the partial itself for a full page.
+func (p *Partial) renderRequest(ctx context.Context, r *http.Request) (template.HTML, error) {
+	renderPartial := p.connector.RenderPartial(r)
+	p.getMetrics().ObserveRequest(connectorName(p.connector), renderPartial)
+
+	if renderPartial {
+		out, err := p.renderWithTarget(ctx, r)
+		if err != nil {
+			return "", err
+		}
+
+		// Render the OOB partials that were added while rendering the target
+		oobOut, err := p.renderDynamicOOB(ctx, r)
+		if err != nil {
+			p.log(ctx).Error("error rendering dynamic OOB partials", "error", err)
+			return "", err
+		}
+
+		return out + oobOut, nil
 	}
 
 	return p.renderSelf(ctx, r)
 }
 
 // WriteWithRequest writes the partial to the http.ResponseWriter.
+// When rendering fails it writes an error response, a page locating the failure in dev mode and a generic error otherwise.
 func (p *Partial) WriteWithRequest(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
 	if p == nil {
 		_, err := fmt.Fprintf(w, "partial is not initialized")
 		return err
 	}
 
-	out, err := p.RenderWithRequest(ctx, r)
+	out, res, err := p.RenderResponse(ctx, r)
 	if err != nil {
-		p.getLogger().Error("error rendering partial", "error", err)
+		p.log(ctx).Error("error rendering partial", "error", err)
+		p.writeError(w, r, err)
 		return err
 	}
 
-	// get headers
-	headers := p.GetResponseHeaders()
-	for k, v := range headers {
-		w.Header().Set(k, v)
-	}
+	res.write(w)
 
 	_, err = w.Write([]byte(out))
 	if err != nil {
-		p.getLogger().Error("error writing partial to response", "error", err)
+		p.log(ctx).Error("error writing partial to response", "error", err)
 		return err
 	}
 
@@ -450,8 +614,31 @@ func (p *Partial) getFuncs(data *Data) template.FuncMap {
 
 	funcs["child"] = childFunc(p, data)
 	funcs["childIf"] = childIfFunc(p, data)
+	funcs["children"] = childrenFunc(p, data)
+	funcs["childrenOf"] = childrenOfFunc(p, data)
 	funcs["selection"] = selectionFunc(p, data)
 	funcs["action"] = actionFunc(p, data)
+	funcs["addOOB"] = addOOBFunc(p, data)
+	funcs["componentState"] = componentStateFunc(p)
+	funcs["componentStateValue"] = componentStateValueFunc(p)
+	funcs["devOverlay"] = devOverlayFunc(p)
+
+	if p.list != nil {
+		var (
+			once     sync.Once
+			items    []renderedItem
+			itemsErr error
+		)
+		rendered := func() ([]renderedItem, error) {
+			once.Do(func() {
+				items, itemsErr = p.renderListItems(data.Ctx, data.Request)
+			})
+			return items, itemsErr
+		}
+
+		funcs["listItems"] = listItemsFunc(p, rendered)
+		funcs["listVersion"] = listVersionFunc(p, data, rendered)
+	}
 
 	funcs["url"] = func() *url.URL {
 		return data.URL
@@ -478,6 +665,13 @@ func (p *Partial) getFuncs(data *Data) template.FuncMap {
 		return template.URL(path.Join(allParts...))
 	}
 
+	funcs["route"] = routeFunc(p)
+
+	funcs["withQuery"] = withQueryFunc(data)
+	funcs["withoutQuery"] = withoutQueryFunc(data)
+	funcs["toggleQuery"] = toggleQueryFunc(data)
+	funcs["queryValues"] = queryValuesFunc(data)
+
 	// Target-related (prefixed with "requestTarget")
 	funcs["requestTargetHeader"] = func() string {
 		return p.getConnector().GetTargetHeader()
@@ -539,6 +733,9 @@ func (p *Partial) getFuncs(data *Data) template.FuncMap {
 
 	funcs["oobSwapIfEnabled"] = func(v string) template.HTMLAttr {
 		if p.swapOOB {
+			if p.oobSwap != "" || p.oobTarget != "" {
+				return template.HTMLAttr(p.getOOBRenderer().OOBSwapAttr(p.oobSwap, p.oobTarget))
+			}
 			return template.HTMLAttr(`x-swap-oob="` + v + `"`)
 		}
 		return template.HTMLAttr("")
@@ -622,13 +819,21 @@ func (p *Partial) getSelectionPartials() map[string]*Partial {
 }
 
 func (p *Partial) GetRequest() *http.Request {
+	if r := p.getRequest(); r != nil {
+		return r
+	}
+	return &http.Request{}
+}
+
+// getRequest returns the request of the partial or its parents, nil before a request is set.
+func (p *Partial) getRequest() *http.Request {
 	if p.request != nil {
 		return p.request
 	}
 	if p.parent != nil {
-		return p.parent.GetRequest()
+		return p.parent.getRequest()
 	}
-	return &http.Request{}
+	return nil
 }
 
 func (p *Partial) getFS() fs.FS {
@@ -703,7 +908,19 @@ func (p *Partial) GetRequestedSelect() string {
 func (p *Partial) renderWithTarget(ctx context.Context, r *http.Request) (template.HTML, error) {
 	requestedTarget := p.getConnector().GetTargetValue(p.GetRequest())
 	if requestedTarget == "" || requestedTarget == p.id {
-		out, err := p.renderSelf(ctx, r)
+		var (
+			out template.HTML
+			err error
+		)
+		if state := getRenderState(ctx); state != nil && state.swapTarget {
+			p.swapOOB = true
+			if p.oobSwap == "" {
+				p.oobSwap = connector.SwapOuterHTML
+			}
+			out, err = p.renderOOB(ctx, r)
+		} else {
+			out, err = p.renderSelf(ctx, r)
+		}
 		if err != nil {
 			return "", err
 		}
@@ -711,7 +928,7 @@ func (p *Partial) renderWithTarget(ctx context.Context, r *http.Request) (templa
 		// Render OOB children of parent if necessary
 		oobOutAll, oobErr := p.renderAllAncestorOOBChildren(ctx, r, true)
 		if oobErr != nil {
-			p.getLogger().Error("error rendering OOB children from ancestors", "error", oobErr)
+			p.log(ctx).Error("error rendering OOB children from ancestors", "error", oobErr)
 			return "", fmt.Errorf("error rendering OOB children from ancestors: %w", oobErr)
 		}
 		out += oobOutAll
@@ -720,7 +937,7 @@ func (p *Partial) renderWithTarget(ctx context.Context, r *http.Request) (templa
 	} else {
 		c := p.recursiveChildLookup(requestedTarget, make(map[string]bool))
 		if c == nil {
-			p.getLogger().Error("requested partial not found in parent", "id", requestedTarget, "parent", p.id)
+			p.log(ctx).Error("requested partial not found in parent", "id", requestedTarget, "parent", p.id)
 			return "", fmt.Errorf("requested partial %s not found in parent %s", requestedTarget, p.id)
 		}
 		return c.renderWithTarget(ctx, r)
@@ -741,8 +958,8 @@ func (p *Partial) recursiveChildLookup(id string, visited map[string]bool) *Part
 		return c
 	}
 
-	for _, child := range p.children {
-		if c := child.recursiveChildLookup(id, visited); c != nil {
+	for _, childID := range p.childOrder {
+		if c := p.children[childID].recursiveChildLookup(id, visited); c != nil {
 			return c
 		}
 	}
@@ -750,12 +967,58 @@ func (p *Partial) recursiveChildLookup(id string, visited map[string]bool) *Part
 	return nil
 }
 
+// getChildren returns the children of the partial in insertion order.
+func (p *Partial) getChildren() []*Partial {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	out := make([]*Partial, 0, len(p.childOrder))
+	for _, id := range p.childOrder {
+		if child, ok := p.children[id]; ok {
+			out = append(out, child)
+		}
+	}
+
+	return out
+}
+
+// getSlotIDs returns the child ids of the named slot in insertion order.
+func (p *Partial) getSlotIDs(slot string) []string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	return append([]string{}, p.slots[slot]...)
+}
+
+// isSlotted reports whether the child id belongs to any named slot.
+func (p *Partial) isSlotted(id string) bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	for _, ids := range p.slots {
+		if containsString(ids, id) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isOOB reports whether the child id is registered as an out-of-band child.
+func (p *Partial) isOOB(id string) bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	_, ok := p.oobChildren[id]
+	return ok
+}
+
 func (p *Partial) renderChildPartial(ctx context.Context, id string, data map[string]any) (template.HTML, error) {
 	p.mu.RLock()
 	child, ok := p.children[id]
 	p.mu.RUnlock()
 	if !ok {
-		p.getLogger().Warn("child partial not found", "id", id)
+		p.log(ctx).Warn("child partial not found", "id", id)
 		return "", nil
 	}
 
@@ -777,7 +1040,7 @@ func (p *Partial) renderChildPartial(ctx context.Context, id string, data map[st
 // renderNamed renders the partial with the given name and templates.
 func (p *Partial) renderSelf(ctx context.Context, r *http.Request) (template.HTML, error) {
 	if len(p.templates) == 0 {
-		p.getLogger().Error("no templates provided for rendering")
+		p.log(ctx).Error("no templates provided for rendering")
 		return "", errors.New("no templates provided for rendering")
 	}
 
@@ -786,25 +1049,78 @@ func (p *Partial) renderSelf(ctx context.Context, r *http.Request) (template.HTM
 		currentURL = r.URL
 	}
 
+	start := time.Now()
+	ctx, span := p.startRenderSpan(ctx, r)
+	defer span.End()
+
 	data := &Data{
 		URL:      currentURL,
 		BasePath: p.getBasePath(),
 		Request:  r,
 		Ctx:      ctx,
 		Data:     p.data,
+		Props:    p.props,
 		Global:   p.getGlobalData(),
 		Service:  p.getServiceData(),
 		Layout:   p.getLayoutData(),
 		Loc:      getLocalizer(ctx),
 		Csrf:     getCsrfToken(ctx),
+		partial:  p,
+	}
+
+	out, err := p.renderFunc()(ctx, p, data)
+	span.RecordError(err)
+	p.getMetrics().ObserveRender(p.id, time.Since(start), err)
+
+	return out, err
+}
+
+// render runs the action of the partial and executes its templates, it is the innermost step of the render chain.
+func render(ctx context.Context, p *Partial, data *Data) (template.HTML, error) {
+	start := time.Now()
+	r := data.Request
+
+	// the items of a list can be set while rendering, so a list renders from a copy and the tree keeps its items
+	if p.list != nil {
+		p = p.cloneWithActions()
+		data.Data = p.data
+		data.partial = p
+	}
+
+	// unlike actions the state of a component is restored on every render, also when the component is not the target
+	if p.component != nil {
+		if err := p.component(ctx, p, data); err != nil {
+			p.log(ctx).Error("error in component", "error", err)
+			return "", p.renderError(fmt.Errorf("error in component: %w", err))
+		}
 	}
 
 	if p.action != nil {
-		var err error
-		p, err = p.action(ctx, p, data)
+		_, span := p.getTracer().StartSpan(ctx, "partial.action", Attr("partial.id", p.id))
+		next, err := p.action(ctx, p, data)
+		span.RecordError(err)
+		span.End()
 		if err != nil {
-			p.getLogger().Error("error in action function", "error", err)
-			return "", fmt.Errorf("error in action function: %w", err)
+			// actions may return a nil partial with the error, so the error is reported on the partial itself
+			p.getMetrics().ObserveActionError(p.id)
+			p.log(ctx).Error("error in action function", "error", err)
+			return "", p.renderError(fmt.Errorf("error in action function: %w", err))
+		}
+		p = next
+	}
+
+	if state := getRenderState(ctx); state != nil {
+		state.collect(p)
+	}
+
+	if p.isListDiffRequest(r) {
+		out, ok, err := p.renderListDiff(ctx, r)
+		if err != nil {
+			p.log(ctx).Error("error rendering list changes", "error", err)
+			return "", err
+		}
+		if ok {
+			return out, nil
 		}
 	}
 
@@ -812,19 +1128,19 @@ func (p *Partial) renderSelf(ctx context.Context, r *http.Request) (template.HTM
 	funcMapPtr := reflect.ValueOf(functions).Pointer()
 
 	cacheKey := p.generateCacheKey(p.templates, funcMapPtr)
-	tmpl, err := p.getOrParseTemplate(cacheKey, functions)
+	tmpl, err := p.getOrParseTemplate(ctx, cacheKey, functions)
 	if err != nil {
-		p.getLogger().Error("error getting or parsing template", "error", err)
-		return "", err
+		p.log(ctx).Error("error getting or parsing template", "error", err)
+		return "", p.renderError(err)
 	}
 
 	var buf bytes.Buffer
 	if err = tmpl.Execute(&buf, data); err != nil {
-		p.getLogger().Error("error executing template", "template", p.templates[0], "error", err)
-		return "", fmt.Errorf("error executing template '%s': %w", p.templates[0], err)
+		p.log(ctx).Error("error executing template", "template", p.templates[0], "error", err)
+		return "", p.renderError(fmt.Errorf("error executing template '%s': %w", p.templates[0], err))
 	}
 
-	return template.HTML(buf.String()), nil
+	return p.annotate(template.HTML(buf.String()), time.Since(start)), nil
 }
 
 func (p *Partial) renderOOBChildren(ctx context.Context, r *http.Request, swapOOB bool, isAncestor bool) (template.HTML, error) {
@@ -832,11 +1148,18 @@ func (p *Partial) renderOOBChildren(ctx context.Context, r *http.Request, swapOO
 	p.mu.RLock()
 	defer p.mu.RUnlock()
 
-	for id := range p.oobChildren {
+	for _, id := range p.oobOrder {
 		if child, ok := p.children[id]; ok {
 			if isAncestor || child.alwaysSwapOOB {
+				state := getRenderState(ctx)
+				if state != nil && !state.markRendered(id) {
+					continue
+				}
 				child.swapOOB = swapOOB
-				childData, err := child.renderSelf(ctx, r)
+				if child.oobSwap == "" && state != nil && state.swapTarget {
+					child.oobSwap = connector.SwapOuterHTML
+				}
+				childData, err := child.renderOOB(ctx, r)
 				if err != nil {
 					return "", fmt.Errorf("error rendering OOB child '%s': %w", id, err)
 				}
@@ -861,12 +1184,22 @@ func (p *Partial) renderAllAncestorOOBChildren(ctx context.Context, r *http.Requ
 	return out, nil
 }
 
-func (p *Partial) getOrParseTemplate(cacheKey string, functions template.FuncMap) (*template.Template, error) {
+func (p *Partial) getOrParseTemplate(ctx context.Context, cacheKey string, functions template.FuncMap) (*template.Template, error) {
+	tracer := p.getTracer()
+	metrics := p.getMetrics()
+
+	_, span := tracer.StartSpan(ctx, "partial.cache", Attr("partial.id", p.id), Attr("partial.templates", p.templates))
 	if tmpl, cached := templateCache.Load(cacheKey); cached && p.useCache {
 		if t, ok := tmpl.(*template.Template); ok {
+			span.SetAttributes(Attr("partial.cache.hit", true))
+			span.End()
+			metrics.ObserveCache(p.id, true)
 			return t, nil
 		}
 	}
+	span.SetAttributes(Attr("partial.cache.hit", false))
+	span.End()
+	metrics.ObserveCache(p.id, false)
 
 	muInterface, _ := mutexCache.LoadOrStore(cacheKey, &sync.Mutex{})
 	mu := muInterface.(*sync.Mutex)
@@ -880,9 +1213,16 @@ func (p *Partial) getOrParseTemplate(cacheKey string, functions template.FuncMap
 		}
 	}
 
+	_, span = tracer.StartSpan(ctx, "partial.parse", Attr("partial.id", p.id), Attr("partial.templates", p.templates))
+	defer span.End()
+
+	start := time.Now()
+	defer func() { metrics.ObserveParse(p.id, time.Since(start)) }()
+
 	t := template.New(path.Base(p.templates[0])).Funcs(functions)
 	tmpl, err := t.ParseFS(p.getFS(), p.templates...)
 	if err != nil {
+		span.RecordError(err)
 		return nil, fmt.Errorf("error parsing templates: %w", err)
 	}
 
@@ -903,11 +1243,27 @@ func (p *Partial) clone() *Partial {
 		parent:            p.parent,
 		request:           p.request,
 		swapOOB:           p.swapOOB,
+		oobSwap:           p.oobSwap,
+		oobTarget:         p.oobTarget,
 		fs:                p.fs,
 		logger:            p.logger,
 		connector:         p.connector,
 		useCache:          p.useCache,
 		selection:         p.selection,
+		list:              p.list.copy(),
+		stateKeys:         p.stateKeys,
+		dev:               p.dev,
+		hooks:             p.hooks,
+		serviceHooks:      p.serviceHooks,
+		tracer:            p.tracer,
+		metrics:           p.metrics,
+		fixtures:          p.fixtures,
+		routes:            p.routes,
+		props:             p.props,
+		dataType:          p.dataType,
+		component:         p.component,
+		status:            p.status,
+		responseHeader:    p.responseHeader.Clone(),
 		templates:         append([]string{}, p.templates...), // Copy the slice
 		combinedFunctions: make(template.FuncMap),
 		basePath:          p.basePath,
@@ -916,7 +1272,10 @@ func (p *Partial) clone() *Partial {
 		globalData:        make(map[string]any),
 		serviceData:       make(map[string]any),
 		children:          make(map[string]*Partial),
+		childOrder:        append([]string{}, p.childOrder...),
 		oobChildren:       make(map[string]struct{}),
+		oobOrder:          append([]string{}, p.oobOrder...),
+		slots:             make(map[string][]string),
 	}
 
 	// Copy the maps
@@ -950,9 +1309,27 @@ func (p *Partial) clone() *Partial {
 		clone.oobChildren[k] = v
 	}
 
+	// Copy the named slots
+	for k, v := range p.slots {
+		clone.slots[k] = append([]string{}, v...)
+	}
+
 	return clone
 }
 
+// cloneWithActions clones the partial together with its actions, which clone leaves out.
+func (p *Partial) cloneWithActions() *Partial {
+	c := p.clone()
+
+	p.mu.RLock()
+	c.action = p.action
+	c.templateAction = p.templateAction
+	c.alwaysSwapOOB = p.alwaysSwapOOB
+	p.mu.RUnlock()
+
+	return c
+}
+
 // Generate a hash of the function names to include in the cache key
 func (p *Partial) generateCacheKey(templates []string, funcMapPtr uintptr) string {
 	var builder strings.Builder
@@ -968,3 +1345,12 @@ func (p *Partial) generateCacheKey(templates []string, funcMapPtr uintptr) strin
 
 	return builder.String()
 }
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
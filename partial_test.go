@@ -884,3 +884,82 @@ func TestGetGlobalDataRecursive(t *testing.T) {
 		t.Errorf("expected 3 results")
 	}
 }
+
+func TestChildrenOrder(t *testing.T) {
+	fsys := &InMemoryFS{
+		Files: map[string]string{
+			"templates/index.html":   `<main>{{ children }}</main><aside>{{ childrenOf "sidebar" }}</aside>`,
+			"templates/item.html":    `<p>{{ .Data.Text }}</p>`,
+			"templates/footer.html":  `<footer {{ oobSwapIfEnabled "true" }}>footer</footer>`,
+			"templates/content.html": `<div>content</div>`,
+		},
+	}
+
+	newItem := func(id string) *Partial {
+		return New("templates/item.html").ID(id).SetData(map[string]any{"Text": id})
+	}
+
+	p := New("templates/index.html").ID("root")
+	for _, id := range []string{"c", "a", "e", "b", "d"} {
+		p.With(newItem(id))
+	}
+	p.WithIn("sidebar", newItem("z"))
+	p.WithIn("sidebar", newItem("y"))
+	p.WithOOB(New("templates/footer.html").ID("footer"))
+	p.With(newItem("a"))
+
+	svc := NewService(&Config{FS: fsys})
+
+	for i := 0; i < 10; i++ {
+		request, _ := http.NewRequest(http.MethodGet, "/", nil)
+		out, err := svc.NewLayout().Set(p).RenderWithRequest(context.Background(), request)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		expected := "<main><p>c</p><p>a</p><p>e</p><p>b</p><p>d</p></main><aside><p>z</p><p>y</p></aside>"
+		if string(out) != expected {
+			t.Fatalf("expected %s, got %s", expected, out)
+		}
+	}
+
+	var ids []string
+	for _, n := range Tree(p).Nodes {
+		ids = append(ids, n.ID)
+	}
+	if got := strings.Join(ids, ","); got != "c,a,e,b,d,z,y,footer" {
+		t.Errorf("expected tree order c,a,e,b,d,z,y,footer, got %s", got)
+	}
+}
+
+func TestOOBChildrenOrder(t *testing.T) {
+	fsys := &InMemoryFS{
+		Files: map[string]string{
+			"templates/index.html":   `{{ child "content" }}`,
+			"templates/content.html": `<div>content</div>`,
+			"templates/oob.html":     `<div {{ oobSwapIfEnabled "true" }}>{{ .Data.Text }}</div>`,
+		},
+	}
+
+	p := New("templates/index.html").ID("root")
+	p.With(New("templates/content.html").ID("content"))
+	for _, id := range []string{"three", "one", "two"} {
+		p.WithOOB(New("templates/oob.html").ID(id).SetData(map[string]any{"Text": id}))
+	}
+
+	svc := NewService(&Config{FS: fsys})
+
+	for i := 0; i < 10; i++ {
+		request, _ := http.NewRequest(http.MethodGet, "/", nil)
+		request.Header.Set("X-Target", "content")
+		out, err := svc.NewLayout().Set(p).RenderWithRequest(context.Background(), request)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		expected := `<div>content</div><div x-swap-oob="true">three</div><div x-swap-oob="true">one</div><div x-swap-oob="true">two</div>`
+		if string(out) != expected {
+			t.Fatalf("expected %s, got %s", expected, out)
+		}
+	}
+}
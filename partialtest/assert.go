@@ -0,0 +1,62 @@
+package partialtest
+
+import (
+	"slices"
+	"testing"
+)
+
+// AssertStatus fails the test when the status code of the response differs.
+func AssertStatus(t testing.TB, resp *Response, status int) {
+	t.Helper()
+
+	if resp.Status != status {
+		t.Errorf("expected status %d, got %d", status, resp.Status)
+	}
+}
+
+// AssertHeader fails the test when the response header differs.
+func AssertHeader(t testing.TB, resp *Response, name, value string) {
+	t.Helper()
+
+	if got := resp.Header.Get(name); got != value {
+		t.Errorf("expected header %s to be %q, got %q", name, value, got)
+	}
+}
+
+// AssertRendered fails the test when an element with one of the ids is missing from the response.
+func AssertRendered(t testing.TB, resp *Response, ids ...string) {
+	t.Helper()
+
+	rendered := resp.IDs()
+	for _, id := range ids {
+		if !slices.Contains(rendered, id) {
+			t.Errorf("expected an element with id %q, the response has %v", id, rendered)
+		}
+	}
+}
+
+// AssertNotRendered fails the test when an element with one of the ids is in the response.
+func AssertNotRendered(t testing.TB, resp *Response, ids ...string) {
+	t.Helper()
+
+	rendered := resp.IDs()
+	for _, id := range ids {
+		if slices.Contains(rendered, id) {
+			t.Errorf("expected no element with id %q", id)
+		}
+	}
+}
+
+// AssertOOB fails the test when the out-of-band fragments of the response differ from the ids, in order.
+func AssertOOB(t testing.TB, resp *Response, ids ...string) {
+	t.Helper()
+
+	got := make([]string, 0, len(resp.OOB))
+	for _, f := range resp.OOB {
+		got = append(got, f.ID)
+	}
+
+	if !slices.Equal(got, ids) {
+		t.Errorf("expected the out-of-band fragments %v, got %v", ids, got)
+	}
+}
@@ -0,0 +1,58 @@
+package partialtest
+
+import (
+	"context"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/partial-coffee/go-partial"
+)
+
+// update is namespaced, so test packages can define their own -update flag.
+var update = flag.Bool("partialtest.update", false, "update the golden files of partialtest.Golden")
+
+// Golden compares the output with the golden file testdata/<name>.golden.
+// Running the tests with -partialtest.update writes the output to the golden file instead.
+func Golden(t testing.TB, name, got string) {
+	t.Helper()
+
+	path := filepath.Join("testdata", name+".golden")
+
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("error creating golden file directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(got), 0o644); err != nil {
+			t.Fatalf("error writing golden file: %v", err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("error reading golden file, run the tests with -partialtest.update to create it: %v", err)
+	}
+
+	if got != string(want) {
+		t.Errorf("output differs from %s, run the tests with -partialtest.update to accept it\ngot:\n%s\nwant:\n%s", path, got, want)
+	}
+}
+
+// GoldenGallery renders every fixture of the gallery on its own and compares it with the golden file
+// testdata/<component>/<partial>/<fixture>.golden, so the fixtures of the gallery double as test cases.
+func GoldenGallery(t *testing.T, g *partial.Gallery) {
+	t.Helper()
+
+	for _, c := range g.Cases() {
+		t.Run(c.Component+"/"+c.Partial+"/"+c.Fixture, func(t *testing.T) {
+			out, err := g.Render(context.Background(), c, partial.GalleryRequest{})
+			if err != nil {
+				t.Fatalf("error rendering fixture: %v", err)
+			}
+
+			Golden(t, filepath.Join(c.Component, c.Partial, c.Fixture), string(out))
+		})
+	}
+}
@@ -0,0 +1,190 @@
+package partialtest
+
+import (
+	"flag"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/partial-coffee/go-partial"
+	"github.com/partial-coffee/go-partial/connector"
+)
+
+var files = map[string]string{
+	"templates/index.html":   `<main id="root">{{ child "content" }}</main>`,
+	"templates/content.html": `<div id="content">{{ selection }}</div>`,
+	"templates/tab1.html":    `<p id="tab1">one</p>`,
+	"templates/tab2.html":    `<p id="tab2">two</p>`,
+	"templates/footer.html":  `<footer {{ oobSwapIfEnabled "true" }} id="footer">footer</footer>`,
+}
+
+func build() *partial.Partial {
+	content := partial.NewID("content", "templates/content.html").
+		WithSelectMap("tab1", map[string]*partial.Partial{
+			"tab1": partial.NewID("tab1", "templates/tab1.html"),
+			"tab2": partial.NewID("tab2", "templates/tab2.html"),
+		})
+
+	return partial.NewID("root", "templates/index.html").
+		With(content).
+		WithOOB(partial.NewID("footer", "templates/footer.html"))
+}
+
+func TestRequests(t *testing.T) {
+	tests := []struct {
+		name     string
+		r        *http.Request
+		c        connector.Connector
+		rendered []string
+		missing  []string
+	}{
+		{"htmx", HTMXRequest("content", "tab2", ""), connector.NewHTMX(nil), []string{"content", "tab2"}, []string{"root", "tab1"}},
+		{"htmx full page", HTMXRequest("", "", ""), connector.NewHTMX(nil), []string{"root", "content", "tab1"}, nil},
+		{"turbo frame", TurboFrameRequest("content", "tab2", ""), connector.NewTurbo(nil), []string{"content", "tab2"}, []string{"root"}},
+		{"unpoly", UnpolyRequest("content", "", ""), connector.NewUnpoly(nil), []string{"content", "tab1"}, []string{"root"}},
+		{"alpine", AlpineRequest("content", "tab2", ""), connector.NewAlpine(nil), []string{"tab2"}, []string{"root"}},
+		{"stimulus", StimulusRequest("content", "", ""), connector.NewStimulus(nil), []string{"content"}, []string{"root"}},
+		{"vue", VueRequest("content", "", ""), connector.NewVue(nil), []string{"content"}, []string{"root"}},
+		{"partial", PartialRequest("content", "", ""), connector.NewPartial(nil), []string{"content"}, []string{"root"}},
+		{"full page", PartialRequest("", "", ""), connector.NewPartial(nil), []string{"root", "content", "tab1"}, []string{"footer"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := Write(NewService(tt.c, files), build(), tt.r)
+
+			AssertStatus(t, resp, http.StatusOK)
+			AssertRendered(t, resp, tt.rendered...)
+			AssertNotRendered(t, resp, tt.missing...)
+		})
+	}
+}
+
+func TestResponse(t *testing.T) {
+	resp := Write(NewService(connector.NewHTMX(nil), files), build(), HTMXRequest("content", "", ""))
+
+	if resp.Main != `<div id="content"><p id="tab1">one</p></div>` {
+		t.Errorf("unexpected main fragment %q", resp.Main)
+	}
+	AssertOOB(t, resp, "footer")
+
+	footer, ok := resp.Fragment("footer")
+	if !ok || footer.Tag != "footer" || footer.Swap != "true" {
+		t.Errorf("unexpected footer fragment %+v", footer)
+	}
+
+	Golden(t, "htmx_content", resp.Body)
+}
+
+func TestSplit(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		main string
+		oob  []Fragment
+	}{
+		{
+			name: "main only",
+			body: `<div id="a"><br><img src="x.png"/><p>text</p></div>`,
+			main: `<div id="a"><br><img src="x.png"/><p>text</p></div>`,
+		},
+		{
+			name: "htmx fragments",
+			body: `<div id="a">a</div><tr hx-swap-oob="beforeend:#rows"><td>row</td></tr><div id="gone" hx-swap-oob="delete"></div>`,
+			main: `<div id="a">a</div>`,
+			oob: []Fragment{
+				{ID: "rows", Tag: "tr", Swap: "beforeend", HTML: `<tr hx-swap-oob="beforeend:#rows"><td>row</td></tr>`},
+				{ID: "gone", Tag: "div", Swap: "delete", HTML: `<div id="gone" hx-swap-oob="delete"></div>`},
+			},
+		},
+		{
+			name: "turbo streams",
+			body: `<turbo-frame id="a">a</turbo-frame><turbo-stream action="replace" target="b"><template><p>b</p></template></turbo-stream>`,
+			main: `<turbo-frame id="a">a</turbo-frame>`,
+			oob: []Fragment{
+				{ID: "b", Tag: "turbo-stream", Swap: "replace", HTML: `<turbo-stream action="replace" target="b"><template><p>b</p></template></turbo-stream>`},
+			},
+		},
+		{
+			name: "unpoly and alpine-ajax fragments",
+			body: `<div id="a">a</div><span id="b" up-hungry>b</span><p id="c" x-sync x-merge="append">c</p>`,
+			main: `<div id="a">a</div>`,
+			oob: []Fragment{
+				{ID: "b", Tag: "span", Swap: "outerHTML", HTML: `<span id="b" up-hungry>b</span>`},
+				{ID: "c", Tag: "p", Swap: "append", HTML: `<p id="c" x-sync x-merge="append">c</p>`},
+			},
+		},
+		{
+			name: "comments and scripts",
+			body: `<!-- <div hx-swap-oob="true"> --><script>if (a < b) {}</script><p>x</p>`,
+			main: `<!-- <div hx-swap-oob="true"> --><script>if (a < b) {}</script><p>x</p>`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			main, oob := Split(tt.body)
+			if main != tt.main {
+				t.Errorf("expected main %q, got %q", tt.main, main)
+			}
+			if len(oob) != len(tt.oob) {
+				t.Fatalf("expected %d fragments, got %+v", len(tt.oob), oob)
+			}
+			for i := range oob {
+				if oob[i] != tt.oob[i] {
+					t.Errorf("expected fragment %+v, got %+v", tt.oob[i], oob[i])
+				}
+			}
+		})
+	}
+}
+
+func TestGoldenGallery(t *testing.T) {
+	gallery := partial.NewGallery(NewService(connector.NewHTMX(nil), files)).
+		Register("tabs", func() *partial.Partial {
+			p := build()
+			p.WithFixture("default", nil, nil)
+			return p
+		})
+
+	GoldenGallery(t, gallery)
+}
+
+// a test package defining its own -update flag must not clash with the flag of partialtest
+var _ = flag.Bool("update", false, "update the golden files of the test package")
+
+// recordingTB records the failures of a test instead of failing it.
+type recordingTB struct {
+	testing.TB
+	failed bool
+}
+
+func (r *recordingTB) Helper() {}
+
+func (r *recordingTB) Errorf(format string, args ...any) {
+	r.failed = true
+}
+
+func TestUpdateFlag(t *testing.T) {
+	if *update {
+		t.Skip("golden files are being updated")
+	}
+
+	path := filepath.Join("testdata", "htmx_content.golden")
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer func() { _ = os.WriteFile(path, want, 0o644) }()
+
+	_ = flag.Set("update", "true")
+	defer func() { _ = flag.Set("update", "false") }()
+
+	// only -partialtest.update writes golden files, the -update flag of the test package is ignored
+	rec := &recordingTB{TB: t}
+	Golden(rec, "htmx_content", "changed")
+	if !rec.failed {
+		t.Error("expected the output to be compared with the golden file")
+	}
+}
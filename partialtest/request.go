@@ -0,0 +1,74 @@
+// Package partialtest provides helpers to test partials: requests as sent by the frontend libraries,
+// responses split into the main fragment and the out-of-band fragments, assertions and golden files.
+package partialtest
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/partial-coffee/go-partial"
+	"github.com/partial-coffee/go-partial/connector"
+)
+
+// NewService returns a service rendering the templates from memory with the connector.
+func NewService(c connector.Connector, files map[string]string) *partial.Service {
+	return partial.NewService(&partial.Config{
+		Connector: c,
+		FS:        &partial.InMemoryFS{Files: files},
+	})
+}
+
+// Request returns a GET request for "/" with the target, select and action headers of the connector,
+// empty values are left out.
+func Request(c connector.Connector, target, sel, action string) *http.Request {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	for header, value := range map[string]string{
+		c.GetTargetHeader(): target,
+		c.GetSelectHeader(): sel,
+		c.GetActionHeader(): action,
+	} {
+		if value != "" {
+			r.Header.Set(header, value)
+		}
+	}
+
+	return r
+}
+
+// HTMXRequest returns a request as sent by htmx, marked with the HX-Request header.
+func HTMXRequest(target, sel, action string) *http.Request {
+	r := Request(connector.NewHTMX(nil), target, sel, action)
+	r.Header.Set("HX-Request", "true")
+	return r
+}
+
+// TurboFrameRequest returns a request as sent by a Turbo frame, the target is the id of the frame.
+func TurboFrameRequest(frame, sel, action string) *http.Request {
+	return Request(connector.NewTurbo(nil), frame, sel, action)
+}
+
+// UnpolyRequest returns a request as sent by Unpoly.
+func UnpolyRequest(target, sel, action string) *http.Request {
+	return Request(connector.NewUnpoly(nil), target, sel, action)
+}
+
+// AlpineRequest returns a request as sent by the Alpine and Alpine AJAX connectors.
+func AlpineRequest(target, sel, action string) *http.Request {
+	return Request(connector.NewAlpine(nil), target, sel, action)
+}
+
+// StimulusRequest returns a request as sent by the Stimulus connector.
+func StimulusRequest(target, sel, action string) *http.Request {
+	return Request(connector.NewStimulus(nil), target, sel, action)
+}
+
+// VueRequest returns a request as sent by the Vue connector.
+func VueRequest(target, sel, action string) *http.Request {
+	return Request(connector.NewVue(nil), target, sel, action)
+}
+
+// PartialRequest returns a request as sent by the default connector.
+func PartialRequest(target, sel, action string) *http.Request {
+	return Request(connector.NewPartial(nil), target, sel, action)
+}
@@ -0,0 +1,318 @@
+package partialtest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/partial-coffee/go-partial"
+)
+
+// voidElements have no closing tag.
+var voidElements = map[string]bool{
+	"area": true, "base": true, "br": true, "col": true, "embed": true, "hr": true, "img": true,
+	"input": true, "link": true, "meta": true, "source": true, "track": true, "wbr": true,
+}
+
+// oobAttributes mark a top-level element as out-of-band.
+var oobAttributes = []string{"hx-swap-oob", "x-swap-oob"}
+
+type (
+	// Response is a recorded response with its body split into the main fragment and the out-of-band fragments.
+	Response struct {
+		Status int
+		Header http.Header
+		Body   string
+		// Main is the body without the out-of-band fragments
+		Main string
+		// OOB are the out-of-band fragments in the order of the body
+		OOB []Fragment
+	}
+
+	// Fragment is an out-of-band element of a response.
+	Fragment struct {
+		// ID is the id of the element, or the id it targets when it has none, e.g. the target of a turbo-stream
+		ID string
+		// Tag is the name of the element
+		Tag string
+		// Swap is the swap strategy, the value of the out-of-band attribute or the action of a turbo-stream
+		Swap string
+		// HTML is the element including its tags
+		HTML string
+	}
+
+	// tag is a start tag found in the body.
+	tag struct {
+		name        string
+		attrs       map[string]string
+		start, end  int
+		closing     bool
+		selfClosing bool
+	}
+)
+
+// Serve serves the request with the handler and returns the response.
+func Serve(h http.Handler, r *http.Request) *Response {
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, r)
+	return Record(rec)
+}
+
+// Write writes the partial with the configuration of the service and returns the response.
+func Write(svc *partial.Service, p *partial.Partial, r *http.Request) *Response {
+	svc.NewLayout().Set(p)
+
+	return Serve(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = p.WriteWithRequest(r.Context(), w, r)
+	}), r)
+}
+
+// Record returns the response of the recorder.
+func Record(rec *httptest.ResponseRecorder) *Response {
+	return NewResponse(rec.Code, rec.Header(), rec.Body.String())
+}
+
+// NewResponse returns the response with the body split into the main fragment and the out-of-band fragments.
+func NewResponse(status int, header http.Header, body string) *Response {
+	resp := &Response{Status: status, Header: header, Body: body}
+	resp.Main, resp.OOB = Split(body)
+	return resp
+}
+
+// Split splits the body into the main fragment and the out-of-band fragments. A top-level element is
+// out-of-band when it carries an hx-swap-oob, x-swap-oob, up-hungry or x-sync attribute or is a turbo-stream.
+func Split(body string) (string, []Fragment) {
+	var main strings.Builder
+	var oob []Fragment
+
+	var open []string
+	var top tag
+	last := 0
+
+	scan(body, func(t tag) {
+		switch {
+		case !t.closing && len(open) == 0 && !t.selfClosing && !voidElements[t.name]:
+			top = t
+			open = append(open, t.name)
+		case !t.closing && len(open) == 0:
+			if fragment, ok := newFragment(t, body[t.start:t.end]); ok {
+				main.WriteString(body[last:t.start])
+				oob = append(oob, fragment)
+				last = t.end
+			}
+		case !t.closing && !t.selfClosing && !voidElements[t.name]:
+			open = append(open, t.name)
+		case t.closing:
+			for i := len(open) - 1; i >= 0; i-- {
+				if open[i] == t.name {
+					open = open[:i]
+					break
+				}
+			}
+			if len(open) == 0 && top.name != "" {
+				if fragment, ok := newFragment(top, body[top.start:t.end]); ok {
+					main.WriteString(body[last:top.start])
+					oob = append(oob, fragment)
+					last = t.end
+				}
+				top = tag{}
+			}
+		}
+	})
+	main.WriteString(body[last:])
+
+	return strings.TrimSpace(main.String()), oob
+}
+
+// newFragment returns the fragment of a top-level element if it is out-of-band.
+func newFragment(t tag, html string) (Fragment, bool) {
+	if t.name == "turbo-stream" {
+		id := t.attrs["target"]
+		if id == "" {
+			id = t.attrs["targets"]
+		}
+		return Fragment{ID: id, Tag: t.name, Swap: t.attrs["action"], HTML: html}, true
+	}
+
+	for _, attr := range oobAttributes {
+		swap, ok := t.attrs[attr]
+		if !ok {
+			continue
+		}
+
+		id := t.attrs["id"]
+		if i := strings.IndexByte(swap, ':'); i >= 0 {
+			if id == "" {
+				id = strings.TrimPrefix(swap[i+1:], "#")
+			}
+			swap = swap[:i]
+		}
+
+		return Fragment{ID: id, Tag: t.name, Swap: swap, HTML: html}, true
+	}
+
+	if _, ok := t.attrs["up-hungry"]; ok {
+		return Fragment{ID: t.attrs["id"], Tag: t.name, Swap: "outerHTML", HTML: html}, true
+	}
+
+	if _, ok := t.attrs["x-sync"]; ok {
+		swap := t.attrs["x-merge"]
+		if swap == "" {
+			swap = "replace"
+		}
+		return Fragment{ID: t.attrs["id"], Tag: t.name, Swap: swap, HTML: html}, true
+	}
+
+	return Fragment{}, false
+}
+
+// IDs returns the ids of all elements in the body in order.
+func (r *Response) IDs() []string {
+	var ids []string
+	scan(r.Body, func(t tag) {
+		if id, ok := t.attrs["id"]; ok && !t.closing {
+			ids = append(ids, id)
+		}
+	})
+
+	return ids
+}
+
+// Fragment returns the out-of-band fragment with the id.
+func (r *Response) Fragment(id string) (Fragment, bool) {
+	for _, f := range r.OOB {
+		if f.ID == id {
+			return f, true
+		}
+	}
+
+	return Fragment{}, false
+}
+
+// scan calls fn for every start and end tag of the body, skipping comments, doctypes and the content of scripts and styles.
+func scan(body string, fn func(t tag)) {
+	i := 0
+	for i < len(body) {
+		lt := strings.IndexByte(body[i:], '<')
+		if lt < 0 {
+			return
+		}
+		i += lt
+
+		switch {
+		case strings.HasPrefix(body[i:], "<!--"):
+			end := strings.Index(body[i+4:], "-->")
+			if end < 0 {
+				return
+			}
+			i += 4 + end + 3
+			continue
+		case strings.HasPrefix(body[i:], "<!"), strings.HasPrefix(body[i:], "<?"):
+			end := strings.IndexByte(body[i:], '>')
+			if end < 0 {
+				return
+			}
+			i += end + 1
+			continue
+		}
+
+		t, ok := parseTag(body, i)
+		if !ok {
+			i++
+			continue
+		}
+		fn(t)
+		i = t.end
+
+		if !t.closing && (t.name == "script" || t.name == "style") {
+			end := strings.Index(strings.ToLower(body[i:]), "</"+t.name)
+			if end < 0 {
+				return
+			}
+			i += end
+		}
+	}
+}
+
+// parseTag parses the tag starting at the '<' at position start.
+func parseTag(body string, start int) (tag, bool) {
+	t := tag{start: start, attrs: map[string]string{}}
+
+	i := start + 1
+	if i < len(body) && body[i] == '/' {
+		t.closing = true
+		i++
+	}
+
+	nameStart := i
+	if i >= len(body) || !isLetter(body[i]) {
+		return tag{}, false
+	}
+	for i < len(body) && isNameChar(body[i]) {
+		i++
+	}
+	t.name = strings.ToLower(body[nameStart:i])
+
+	for i < len(body) {
+		for i < len(body) && isSpace(body[i]) {
+			i++
+		}
+		if i >= len(body) {
+			return tag{}, false
+		}
+
+		switch body[i] {
+		case '>':
+			t.end = i + 1
+			return t, true
+		case '/':
+			t.selfClosing = true
+			i++
+			continue
+		}
+
+		attrStart := i
+		for i < len(body) && !isSpace(body[i]) && body[i] != '=' && body[i] != '>' && body[i] != '/' {
+			i++
+		}
+		name := strings.ToLower(body[attrStart:i])
+
+		var value string
+		if i < len(body) && body[i] == '=' {
+			i++
+			if i < len(body) && (body[i] == '"' || body[i] == '\'') {
+				quote := body[i]
+				end := strings.IndexByte(body[i+1:], quote)
+				if end < 0 {
+					return tag{}, false
+				}
+				value = body[i+1 : i+1+end]
+				i += end + 2
+			} else {
+				valueStart := i
+				for i < len(body) && !isSpace(body[i]) && body[i] != '>' {
+					i++
+				}
+				value = body[valueStart:i]
+			}
+		}
+
+		if name != "" {
+			t.attrs[name] = value
+		}
+	}
+
+	return tag{}, false
+}
+
+func isLetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isNameChar(c byte) bool {
+	return isLetter(c) || (c >= '0' && c <= '9') || c == '-' || c == ':'
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+}
@@ -0,0 +1,287 @@
+package partial
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"net/url"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalidQueryState is returned when the type of a QueryState is not a struct of supported fields.
+var ErrInvalidQueryState = errors.New("invalid query state")
+
+// QueryState binds a filter struct to the query parameters of a URL, so lists rendered from it stay
+// shareable and the back button restores them. The fields are named by their query tag, e.g. `query:"tag"`,
+// or by their lowercased name, a tag of "-" skips the field. Strings, bools, numbers and slices of them are supported.
+// Parameters that are not fields are kept, zero fields are left out of the URL.
+type QueryState[T any] struct {
+	Value T
+
+	path  string
+	other url.Values
+}
+
+// ParseQueryState reads the state from the query of the URL, fields without a parameter keep the value of defaults.
+func ParseQueryState[T any](u *url.URL, defaults T) (*QueryState[T], error) {
+	s := &QueryState[T]{Value: defaults, other: url.Values{}}
+	if u == nil {
+		u = &url.URL{}
+	}
+	s.path = u.Path
+
+	v := reflect.ValueOf(&s.Value).Elem()
+	if v.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("%w: %s is not a struct", ErrInvalidQueryState, v.Type())
+	}
+
+	fields := queryFields(v.Type())
+	for key, values := range u.Query() {
+		i, ok := fields[key]
+		if !ok {
+			s.other[key] = values
+			continue
+		}
+
+		if err := setQueryField(v.Field(i), values); err != nil {
+			return nil, fmt.Errorf("query param %q: %w", key, err)
+		}
+	}
+
+	return s, nil
+}
+
+// Values returns the query parameters of the state, including the parameters that are not fields.
+func (s *QueryState[T]) Values() url.Values {
+	out := url.Values{}
+	for k, v := range s.other {
+		out[k] = append([]string{}, v...)
+	}
+
+	v := reflect.ValueOf(s.Value)
+	for key, i := range queryFields(v.Type()) {
+		field := v.Field(i)
+		if field.IsZero() {
+			continue
+		}
+
+		if field.Kind() == reflect.Slice {
+			for j := 0; j < field.Len(); j++ {
+				out.Add(key, formatQueryValue(field.Index(j)))
+			}
+			continue
+		}
+		out.Set(key, formatQueryValue(field))
+	}
+
+	return out
+}
+
+// URL returns the path with the query of the state.
+func (s *QueryState[T]) URL() template.URL {
+	return queryURL(s.path, s.Values())
+}
+
+// With returns the URL of the state changed by fn, the state itself is left unchanged.
+func (s *QueryState[T]) With(fn func(v *T)) template.URL {
+	changed := *s
+
+	// the slices are copied, so fn can change their elements
+	v := reflect.ValueOf(&changed.Value).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Field(i)
+		if field.Kind() != reflect.Slice || field.IsNil() || !field.CanSet() {
+			continue
+		}
+		c := reflect.MakeSlice(field.Type(), field.Len(), field.Len())
+		reflect.Copy(c, field)
+		field.Set(c)
+	}
+
+	fn(&changed.Value)
+	return changed.URL()
+}
+
+// queryFields returns the index of the supported fields by their parameter name.
+func queryFields(t reflect.Type) map[string]int {
+	fields := make(map[string]int)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+
+		name := strings.ToLower(f.Name)
+		if tag, ok := f.Tag.Lookup("query"); ok {
+			if tag == "-" {
+				continue
+			}
+			if tag != "" {
+				name = tag
+			}
+		}
+
+		kind := f.Type.Kind()
+		if kind == reflect.Slice {
+			kind = f.Type.Elem().Kind()
+		}
+		if isQueryKind(kind) {
+			fields[name] = i
+		}
+	}
+
+	return fields
+}
+
+func isQueryKind(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+
+	return false
+}
+
+func setQueryField(field reflect.Value, values []string) error {
+	if field.Kind() == reflect.Slice {
+		out := reflect.MakeSlice(field.Type(), len(values), len(values))
+		for i, s := range values {
+			if err := setQueryValue(out.Index(i), s); err != nil {
+				return err
+			}
+		}
+		field.Set(out)
+		return nil
+	}
+
+	return setQueryValue(field, values[len(values)-1])
+}
+
+func setQueryValue(v reflect.Value, s string) error {
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	}
+
+	return nil
+}
+
+func formatQueryValue(v reflect.Value) string {
+	switch v.Kind() {
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'f', -1, v.Type().Bits())
+	default:
+		return fmt.Sprint(v.Interface())
+	}
+}
+
+// queryURL returns the path with the query, without a question mark when the query is empty.
+// A path starting with several slashes is reduced to one, so the link cannot point to another host.
+func queryURL(path string, values url.Values) template.URL {
+	if strings.HasPrefix(path, "//") {
+		path = "/" + strings.TrimLeft(path, "/")
+	}
+
+	u := url.URL{Path: path, RawQuery: values.Encode()}
+	return template.URL(u.String())
+}
+
+// currentQuery returns a copy of the query of the URL.
+func currentQuery(u *url.URL) (string, url.Values) {
+	if u == nil {
+		return "", url.Values{}
+	}
+
+	return u.Path, u.Query()
+}
+
+// withQueryFunc returns the current URL with the parameters set, they come in key-value pairs.
+func withQueryFunc(data *Data) func(pairs ...any) (template.URL, error) {
+	return func(pairs ...any) (template.URL, error) {
+		if len(pairs)%2 != 0 {
+			return "", errors.New("withQuery: params come in key-value pairs")
+		}
+
+		path, q := currentQuery(data.URL)
+		for i := 0; i < len(pairs); i += 2 {
+			key, ok := pairs[i].(string)
+			if !ok {
+				return "", fmt.Errorf("withQuery: key %v is not a string", pairs[i])
+			}
+			q.Set(key, fmt.Sprint(pairs[i+1]))
+		}
+
+		return queryURL(path, q), nil
+	}
+}
+
+// withoutQueryFunc returns the current URL without the parameters.
+func withoutQueryFunc(data *Data) func(keys ...string) template.URL {
+	return func(keys ...string) template.URL {
+		path, q := currentQuery(data.URL)
+		for _, key := range keys {
+			q.Del(key)
+		}
+
+		return queryURL(path, q)
+	}
+}
+
+// toggleQueryFunc returns the current URL with the value removed from the parameter when present and added otherwise.
+func toggleQueryFunc(data *Data) func(key string, value any) template.URL {
+	return func(key string, value any) template.URL {
+		path, q := currentQuery(data.URL)
+		s := fmt.Sprint(value)
+
+		if values := q[key]; slices.Contains(values, s) {
+			values = slices.DeleteFunc(slices.Clone(values), func(v string) bool { return v == s })
+			if len(values) == 0 {
+				q.Del(key)
+			} else {
+				q[key] = values
+			}
+		} else {
+			q.Add(key, s)
+		}
+
+		return queryURL(path, q)
+	}
+}
+
+// queryValuesFunc returns the values of the parameter in the current URL.
+func queryValuesFunc(data *Data) func(key string) []string {
+	return func(key string) []string {
+		_, q := currentQuery(data.URL)
+		return q[key]
+	}
+}
@@ -0,0 +1,123 @@
+package partial
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestQueryFunctions(t *testing.T) {
+	fsys := &InMemoryFS{
+		Files: map[string]string{
+			"templates/index.html": `{{ withQuery "page" 2 }}|{{ withQuery "page" 1 "sort" "name" }}|{{ withoutQuery "sort" }}|{{ toggleQuery "tag" "go" }}|{{ toggleQuery "tag" "web" }}|{{ range queryValues "tag" }}{{ . }};{{ end }}`,
+		},
+	}
+
+	tests := []struct {
+		name     string
+		url      string
+		expected string
+	}{
+		{
+			name:     "with params",
+			url:      "/items?page=1&sort=date&tag=go&tag=html",
+			expected: `/items?page=2&amp;sort=date&amp;tag=go&amp;tag=html|/items?page=1&amp;sort=name&amp;tag=go&amp;tag=html|/items?page=1&amp;tag=go&amp;tag=html|/items?page=1&amp;sort=date&amp;tag=html|/items?page=1&amp;sort=date&amp;tag=go&amp;tag=html&amp;tag=web|go;html;`,
+		},
+		{
+			name:     "without params",
+			url:      "/items",
+			expected: `/items?page=2|/items?page=1&amp;sort=name|/items|/items?tag=go|/items?tag=web|`,
+		},
+		{
+			name:     "protocol-relative path",
+			url:      "//evil.com/x",
+			expected: `/evil.com/x?page=2|/evil.com/x?page=1&amp;sort=name|/evil.com/x|/evil.com/x?tag=go|/evil.com/x?tag=web|`,
+		},
+		{
+			name:     "backslash path",
+			url:      "/\\evil.com/x",
+			expected: `/%5Cevil.com/x?page=2|/%5Cevil.com/x?page=1&amp;sort=name|/%5Cevil.com/x|/%5Cevil.com/x?tag=go|/%5Cevil.com/x?tag=web|`,
+		},
+		{
+			name:     "last value toggled off",
+			url:      "/items?tag=go",
+			expected: `/items?page=2&amp;tag=go|/items?page=1&amp;sort=name&amp;tag=go|/items?tag=go|/items|/items?tag=go&amp;tag=web|go;`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, err := url.ParseRequestURI(tt.url)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			r := &http.Request{Method: http.MethodGet, URL: u, Header: http.Header{}}
+			out, err := NewService(&Config{FS: fsys}).NewLayout().Set(New("templates/index.html")).RenderWithRequest(context.Background(), r)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if string(out) != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, out)
+			}
+		})
+	}
+}
+
+func TestQueryState(t *testing.T) {
+	type filter struct {
+		Search   string   `query:"q"`
+		Tags     []string `query:"tag"`
+		Page     int
+		Archived bool
+		MinPrice float64 `query:"min"`
+		Internal string  `query:"-"`
+	}
+
+	u, _ := url.Parse("/items?q=shoes&tag=red&tag=blue&page=3&ref=mail&Internal=x")
+	state, err := ParseQueryState(u, filter{Page: 1, MinPrice: 9.5})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := filter{Search: "shoes", Tags: []string{"red", "blue"}, Page: 3, MinPrice: 9.5}
+	if !reflect.DeepEqual(state.Value, expected) {
+		t.Errorf("expected %+v, got %+v", expected, state.Value)
+	}
+
+	if got := state.URL(); got != "/items?Internal=x&min=9.5&page=3&q=shoes&ref=mail&tag=red&tag=blue" {
+		t.Errorf("unexpected URL %q", got)
+	}
+
+	next := state.With(func(f *filter) {
+		f.Page++
+		f.Search = ""
+		f.Archived = true
+	})
+	if next != "/items?Internal=x&archived=true&min=9.5&page=4&ref=mail&tag=red&tag=blue" {
+		t.Errorf("unexpected URL %q", next)
+	}
+	if state.Value.Page != 3 {
+		t.Errorf("expected With to leave the state unchanged")
+	}
+
+	if next = state.With(func(f *filter) { f.Tags[0] = "green" }); next != "/items?Internal=x&min=9.5&page=3&q=shoes&ref=mail&tag=green&tag=blue" {
+		t.Errorf("unexpected URL %q", next)
+	}
+	if state.Value.Tags[0] != "red" {
+		t.Errorf("expected With to leave the slices of the state unchanged, got %v", state.Value.Tags)
+	}
+
+	t.Run("errors", func(t *testing.T) {
+		u, _ := url.Parse("/items?page=two")
+		if _, err := ParseQueryState(u, filter{}); err == nil || err.Error() != `query param "page": strconv.ParseInt: parsing "two": invalid syntax` {
+			t.Errorf("unexpected error %v", err)
+		}
+
+		if _, err := ParseQueryState(u, "not a struct"); !errors.Is(err, ErrInvalidQueryState) {
+			t.Errorf("expected ErrInvalidQueryState, got %v", err)
+		}
+	})
+}
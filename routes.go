@@ -0,0 +1,141 @@
+package partial
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+var (
+	// ErrUnknownRoute is returned when a URL is built for a route name that is not registered.
+	ErrUnknownRoute = errors.New("unknown route")
+	// ErrMissingRouteParam is returned when a URL is built without a value for a wildcard of the route.
+	ErrMissingRouteParam = errors.New("missing route param")
+)
+
+// Route registers a named route with a ServeMux pattern, e.g. "GET /items/{id}", so URLs can be built
+// with URL and the route template function instead of hardcoding them.
+func (svc *Service) Route(name, pattern string) *Service {
+	svc.routes[name] = routePath(pattern)
+	return svc
+}
+
+// Name registers the pattern of the page as a named route of the service.
+func (pg *Page) Name(name string) *Page {
+	pg.service.Route(name, pg.pattern)
+	return pg
+}
+
+// URL builds the URL of the named route, params come in key-value pairs. Keys that are wildcards of the
+// pattern fill the path, the other keys are added as query parameters, e.g. target, select and action
+// for links that must also work without JavaScript.
+func (svc *Service) URL(name string, params ...any) (string, error) {
+	return buildRoute(svc.routes, name, "", params)
+}
+
+// routeFunc returns the route template function, the URL is prefixed with the base path of the partial.
+func routeFunc(p *Partial) func(name string, params ...any) (string, error) {
+	return func(name string, params ...any) (string, error) {
+		return buildRoute(p.getRoutes(), name, p.getBasePath(), params)
+	}
+}
+
+func (p *Partial) getRoutes() map[string]string {
+	if p.routes != nil {
+		return p.routes
+	}
+
+	if p.parent != nil {
+		return p.parent.getRoutes()
+	}
+
+	return nil
+}
+
+// routePath returns the path of a ServeMux pattern, without the method and the host.
+func routePath(pattern string) string {
+	if i := strings.IndexAny(pattern, " \t"); i >= 0 {
+		pattern = strings.TrimLeft(pattern[i:], " \t")
+	}
+	if i := strings.IndexByte(pattern, '/'); i > 0 {
+		pattern = pattern[i:]
+	}
+
+	return strings.ReplaceAll(pattern, "{$}", "")
+}
+
+// routeParams returns the names of the wildcards of the route path.
+func routeParams(routePath string) []string {
+	var names []string
+	for _, m := range patternWildcard.FindAllStringSubmatch(routePath, -1) {
+		names = append(names, m[1])
+	}
+
+	return names
+}
+
+func buildRoute(routes map[string]string, name, basePath string, params []any) (string, error) {
+	pattern, ok := routes[name]
+	if !ok {
+		return "", fmt.Errorf("%w %q", ErrUnknownRoute, name)
+	}
+
+	if len(params)%2 != 0 {
+		return "", fmt.Errorf("route %q: params come in key-value pairs", name)
+	}
+
+	values := make(map[string]string, len(params)/2)
+	var keys []string
+	for i := 0; i < len(params); i += 2 {
+		key, ok := params[i].(string)
+		if !ok {
+			return "", fmt.Errorf("route %q: param key %v is not a string", name, params[i])
+		}
+		if _, ok = values[key]; !ok {
+			keys = append(keys, key)
+		}
+		values[key] = fmt.Sprint(params[i+1])
+	}
+
+	var missing error
+	used := make(map[string]struct{})
+	out := patternWildcard.ReplaceAllStringFunc(pattern, func(wildcard string) string {
+		param := patternWildcard.FindStringSubmatch(wildcard)[1]
+		value, ok := values[param]
+		if !ok {
+			missing = fmt.Errorf("route %q: %w %q", name, ErrMissingRouteParam, param)
+			return ""
+		}
+		used[param] = struct{}{}
+
+		if strings.HasSuffix(wildcard, "...}") {
+			segments := strings.Split(value, "/")
+			for i, s := range segments {
+				segments[i] = url.PathEscape(s)
+			}
+			return strings.Join(segments, "/")
+		}
+
+		return url.PathEscape(value)
+	})
+	if missing != nil {
+		return "", missing
+	}
+
+	if basePath = strings.TrimSuffix(basePath, "/"); basePath != "" {
+		out = basePath + out
+	}
+
+	query := url.Values{}
+	for _, key := range keys {
+		if _, ok := used[key]; !ok {
+			query.Set(key, values[key])
+		}
+	}
+	if len(query) > 0 {
+		out += "?" + query.Encode()
+	}
+
+	return out, nil
+}
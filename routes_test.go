@@ -0,0 +1,93 @@
+package partial
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestRoutes(t *testing.T) {
+	svc := NewService(&Config{}).
+		Route("home", "GET /{$}").
+		Route("item", "GET example.com/items/{id}").
+		Route("files", "/files/{path...}")
+	svc.Handle("POST /items/{id}/comments", func(r *http.Request) *Partial { return nil }).Name("comments")
+
+	tests := []struct {
+		name     string
+		route    string
+		params   []any
+		expected string
+		err      error
+	}{
+		{name: "no params", route: "home", expected: "/"},
+		{name: "wildcard", route: "item", params: []any{"id", 7}, expected: "/items/7"},
+		{name: "escaped", route: "item", params: []any{"id", "a b/c"}, expected: "/items/a%20b%2Fc"},
+		{name: "rest wildcard", route: "files", params: []any{"path", "docs/a b.txt"}, expected: "/files/docs/a%20b.txt"},
+		{name: "page name", route: "comments", params: []any{"id", 3}, expected: "/items/3/comments"},
+		{name: "query", route: "item", params: []any{"id", 7, "target", "content", "action", "edit"}, expected: "/items/7?action=edit&target=content"},
+		{name: "unknown route", route: "missing", err: ErrUnknownRoute},
+		{name: "missing param", route: "item", params: []any{"target", "content"}, err: ErrMissingRouteParam},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := svc.URL(tt.route, tt.params...)
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Errorf("expected error %v, got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+
+	t.Run("template", func(t *testing.T) {
+		fsys := &InMemoryFS{
+			Files: map[string]string{
+				"templates/index.html": `<a href="{{ route "item" "id" .Data.ID "target" "content" }}">item</a>`,
+				"templates/broken.html": `{{ route "nope" }}
+{{ route "item" "target" "content" }}
+{{ route "item" .Data.Key 1 }}`,
+			},
+		}
+		svc := NewService(&Config{FS: fsys}).Route("item", "/items/{id}")
+
+		p := NewID("root", "templates/index.html").SetData(map[string]any{"ID": 5}).SetBasePath("/admin/")
+		r, _ := http.NewRequest(http.MethodGet, "/", nil)
+		out, err := svc.NewLayout().Set(p).RenderWithRequest(context.Background(), r)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if expected := `<a href="/admin/items/5?target=content">item</a>`; string(out) != expected {
+			t.Errorf("expected %q, got %q", expected, out)
+		}
+
+		broken := NewID("broken", "templates/broken.html")
+		svc.NewLayout().Set(broken)
+
+		var messages []string
+		for _, issue := range Check(broken) {
+			messages = append(messages, issue.Location+": "+issue.Message)
+		}
+		expected := []string{
+			`broken.html:1:9: route "nope" is not registered`,
+			`broken.html:2:9: route "item" needs the param "id"`,
+		}
+		if strings.Join(messages, "\n") != strings.Join(expected, "\n") {
+			t.Errorf("expected issues\n%s\ngot\n%s", strings.Join(expected, "\n"), strings.Join(messages, "\n"))
+		}
+
+		if _, err = broken.RenderWithRequest(context.Background(), r); !errors.Is(err, ErrUnknownRoute) {
+			t.Errorf("expected the render to fail with ErrUnknownRoute, got %v", err)
+		}
+	})
+}
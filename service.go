@@ -12,6 +12,7 @@ import (
 )
 
 type (
+	// Logger receives the warnings and errors of the renders, a logger that also implements ContextLogger receives the context.
 	Logger interface {
 		Warn(msg string, args ...any)
 		Error(msg string, args ...any)
@@ -23,6 +24,16 @@ type (
 		FuncMap   template.FuncMap
 		Logger    Logger
 		FS        fs.FS
+		// StateKeys are the keys used to sign the state of components, the first key signs and all keys verify
+		StateKeys [][]byte
+		// DevMode annotates the output of every partial, see SetDevMode
+		DevMode bool
+		// DevAnnotation selects how the output is annotated in dev mode
+		DevAnnotation Annotation
+		// Tracer opens a span for every render, action, cache lookup and template parse
+		Tracer Tracer
+		// Metrics receives the latency, cache and request measurements, see NewExpvarMetrics
+		Metrics Metrics
 	}
 
 	Service struct {
@@ -31,6 +42,9 @@ type (
 		combinedFunctions template.FuncMap
 		connector         connector.Connector
 		funcMapLock       sync.RWMutex // Add a read-write mutex
+		hooks             *renderHooks
+		mux               *http.ServeMux
+		routes            map[string]string
 	}
 
 	Layout struct {
@@ -62,6 +76,9 @@ func NewService(cfg *Config) *Service {
 		funcMapLock:       sync.RWMutex{},
 		combinedFunctions: cfg.FuncMap,
 		connector:         cfg.Connector,
+		hooks:             &renderHooks{},
+		mux:               http.NewServeMux(),
+		routes:            make(map[string]string),
 	}
 }
 
@@ -100,7 +117,7 @@ func (svc *Service) MergeFuncMap(funcMap template.FuncMap) {
 
 	for k, v := range funcMap {
 		if _, ok := protectedFunctionNames[k]; ok {
-			svc.config.Logger.Warn("function name is protected and cannot be overwritten", "function", k)
+			svc.log(context.Background()).Warn("function name is protected and cannot be overwritten", "function", k)
 			continue
 		}
 		// Modify the existing map directly
@@ -157,7 +174,7 @@ func (l *Layout) MergeFuncMap(funcMap template.FuncMap) {
 
 	for k, v := range funcMap {
 		if _, ok := protectedFunctionNames[k]; ok {
-			l.service.config.Logger.Warn("function name is protected and cannot be overwritten", "function", k)
+			l.service.log(context.Background()).Warn("function name is protected and cannot be overwritten", "function", k)
 			continue
 		}
 		// Modify the existing map directly
@@ -174,15 +191,22 @@ func (l *Layout) getFuncMap() template.FuncMap {
 
 // RenderWithRequest renders the partial with the given http.Request.
 func (l *Layout) RenderWithRequest(ctx context.Context, r *http.Request) (template.HTML, error) {
+	out, _, err := l.RenderResponse(ctx, r)
+	return out, err
+}
+
+// RenderResponse renders the layout like RenderWithRequest and returns the status code and the headers
+// set while rendering, see Partial.RenderResponse.
+func (l *Layout) RenderResponse(ctx context.Context, r *http.Request) (template.HTML, *Response, error) {
 	l.request = r
 
 	if l.wrapper != nil {
 		l.wrapper.With(l.content)
 		// Render the wrapper
-		return l.wrapper.RenderWithRequest(ctx, r)
+		return l.wrapper.RenderResponse(ctx, r)
 	} else {
 		// Render the content directly
-		return l.content.RenderWithRequest(ctx, r)
+		return l.content.RenderResponse(ctx, r)
 	}
 }
 
@@ -190,30 +214,34 @@ func (l *Layout) RenderWithRequest(ctx context.Context, r *http.Request) (templa
 func (l *Layout) WriteWithRequest(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
 	l.request = r
 
-	if l.connector.RenderPartial(r) {
+	conn := l.connector
+	if conn == nil {
+		conn = connector.NewPartial(nil)
+	}
+
+	// without a wrapper the content is the whole page
+	if conn.RenderPartial(r) || l.wrapper == nil {
 		if l.wrapper != nil {
 			l.content.parent = l.wrapper
 		}
 		err := l.content.WriteWithRequest(ctx, w, r)
 		if err != nil {
 			if l.service.config.Logger != nil {
-				l.service.config.Logger.Error("error rendering layout", "error", err)
+				l.service.log(ctx).Error("error rendering layout", "error", err)
 			}
 			return err
 		}
 		return nil
 	}
 
-	if l.wrapper != nil {
-		l.wrapper.With(l.content)
+	l.wrapper.With(l.content)
 
-		err := l.wrapper.WriteWithRequest(ctx, w, r)
-		if err != nil {
-			if l.service.config.Logger != nil {
-				l.service.config.Logger.Error("error rendering layout", "error", err)
-			}
-			return err
+	err := l.wrapper.WriteWithRequest(ctx, w, r)
+	if err != nil {
+		if l.service.config.Logger != nil {
+			l.service.log(ctx).Error("error rendering layout", "error", err)
 		}
+		return err
 	}
 
 	return nil
@@ -237,6 +265,14 @@ func (l *Layout) applyConfigToPartial(p *Partial) {
 		p.logger = l.service.config.Logger
 	}
 	p.useCache = l.service.config.UseCache
+	p.stateKeys = l.service.config.StateKeys
+	p.serviceHooks = l.service.hooks
+	p.tracer = l.service.config.Tracer
+	p.metrics = l.service.config.Metrics
+	p.routes = l.service.routes
+	if l.service.config.DevMode {
+		p.dev = &devOptions{annotation: l.service.config.DevAnnotation}
+	}
 	p.serviceData = l.service.data
 	p.layoutData = l.data
 	p.request = l.request
@@ -0,0 +1,488 @@
+package partial
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/partial-coffee/go-partial/connector"
+)
+
+const (
+	// websocketGUID is the magic value of the WebSocket handshake, see RFC 6455 section 1.3.
+	websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+	opContinuation = 0x0
+	opText         = 0x1
+	opBinary       = 0x2
+	opClose        = 0x8
+	opPing         = 0x9
+	opPong         = 0xA
+)
+
+type (
+	// SocketConfig configures a Socket.
+	SocketConfig struct {
+		// MaxMessageSize is the maximum size of a message sent by the client, defaults to 1 MiB
+		MaxMessageSize int64
+		// CheckOrigin reports whether the upgrade request may connect, by default only requests
+		// without an Origin header or from the same host are accepted. Browsers send cookies with
+		// cross-origin handshakes, so accepting any origin lets other sites act as the user.
+		CheckOrigin func(r *http.Request) bool
+		// ReadTimeout is the time the client may stay silent before the connection is closed, defaults to 60 seconds.
+		// The socket pings the client at half the timeout, browsers answer pings without any script.
+		ReadTimeout time.Duration
+	}
+
+	// Socket is a WebSocket endpoint for two-way live partials.
+	// Every message of the client is resolved like a partial request and answered with the rendered fragments.
+	Socket struct {
+		service *Service
+		config  *SocketConfig
+		handler func(r *http.Request) *Partial
+	}
+
+	// SocketMessage is a message sent by the client.
+	SocketMessage struct {
+		// Target is the id of the partial to render
+		Target string `json:"target"`
+		// Select is the selection key
+		Select string `json:"select"`
+		// Action is the requested action
+		Action string `json:"action"`
+		// Values contains the form values, a value can be a string, a number, a boolean or a list of those
+		Values map[string]any `json:"values"`
+	}
+
+	// SocketError is sent to the client as JSON when a message cannot be rendered, the connection stays open.
+	SocketError struct {
+		// Error describes the error, it is only detailed in dev mode
+		Error string `json:"error"`
+		// Target and Action are those of the failed message
+		Target string `json:"target,omitempty"`
+		Action string `json:"action,omitempty"`
+	}
+
+	// socketConn is a server side WebSocket connection.
+	socketConn struct {
+		conn        net.Conn
+		reader      *bufio.Reader
+		maxSize     int64
+		readTimeout time.Duration
+		mu          sync.Mutex
+	}
+)
+
+var errSocketClosed = errors.New("websocket closed by client")
+
+// NewSocket returns a WebSocket endpoint that builds the partial tree for every message with the handler.
+// The handler receives a request derived from the upgrade request carrying the target, select and action of the
+// message in the connector headers and the values of the message as form values.
+// The target is rendered as out-of-band fragment, followed by the OOB children of its ancestors and
+// the OOB partials added during the render, so every fragment is swapped by its id.
+// A message that fails to render is answered with a SocketError.
+func (svc *Service) NewSocket(cfg *SocketConfig, handler func(r *http.Request) *Partial) *Socket {
+	if cfg == nil {
+		cfg = &SocketConfig{}
+	}
+
+	if cfg.MaxMessageSize <= 0 {
+		cfg.MaxMessageSize = 1 << 20
+	}
+
+	if cfg.CheckOrigin == nil {
+		cfg.CheckOrigin = sameOrigin
+	}
+
+	if cfg.ReadTimeout <= 0 {
+		cfg.ReadTimeout = time.Minute
+	}
+
+	return &Socket{
+		service: svc,
+		config:  cfg,
+		handler: handler,
+	}
+}
+
+// ServeHTTP upgrades the connection and answers the messages of the client until it disconnects.
+func (s *Socket) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !s.config.CheckOrigin(r) {
+		http.Error(w, "origin not allowed", http.StatusForbidden)
+		s.service.config.Logger.Warn("websocket origin not allowed", "origin", r.Header.Get("Origin"))
+		return
+	}
+
+	conn, err := upgradeWebsocket(w, r, s.config.MaxMessageSize)
+	if err != nil {
+		s.service.config.Logger.Warn("error upgrading websocket connection", "error", err)
+		return
+	}
+	defer conn.close()
+
+	conn.readTimeout = s.config.ReadTimeout
+	done := make(chan struct{})
+	defer close(done)
+	go conn.keepAlive(max(s.config.ReadTimeout/2, time.Millisecond), done)
+
+	ctx := r.Context()
+	for {
+		payload, err := conn.readMessage()
+		if err != nil {
+			if !errors.Is(err, errSocketClosed) && !errors.Is(err, io.EOF) {
+				s.service.config.Logger.Warn("error reading websocket message", "error", err)
+			}
+			return
+		}
+
+		var msg SocketMessage
+		if err = json.Unmarshal(payload, &msg); err != nil {
+			s.service.config.Logger.Warn("invalid websocket message", "error", err)
+			continue
+		}
+
+		out, err := s.render(ctx, r, msg)
+		if err != nil {
+			// a failed action or validation is answered with an error, the client can keep using the connection
+			s.service.config.Logger.Error("error rendering websocket message", "target", msg.Target, "action", msg.Action, "error", err)
+			if err = conn.writeFrame(opText, s.errorMessage(msg, err)); err != nil {
+				s.service.config.Logger.Warn("error writing websocket message", "error", err)
+				return
+			}
+			continue
+		}
+
+		if err = conn.writeFrame(opText, []byte(out)); err != nil {
+			s.service.config.Logger.Warn("error writing websocket message", "error", err)
+			return
+		}
+	}
+}
+
+// render resolves the message like a partial request and renders the fragments.
+func (s *Socket) render(ctx context.Context, upgrade *http.Request, msg SocketMessage) (template.HTML, error) {
+	layout := s.service.NewLayout()
+	c := layout.Connector()
+	if c == nil {
+		c = connector.NewPartial(nil)
+	}
+
+	r := newSocketRequest(ctx, upgrade, c, msg)
+
+	p := s.handler(r)
+	if p == nil {
+		return "", errors.New("partial is not initialized")
+	}
+
+	layout.Set(p)
+	p.request = r
+	p.connector = c
+
+	ctx, state := withRenderState(ctx)
+	state.swapTarget = true
+
+	out, err := p.renderWithTarget(ctx, r)
+	if err != nil {
+		return "", err
+	}
+
+	oobOut, err := p.renderDynamicOOB(ctx, r)
+	if err != nil {
+		return "", err
+	}
+
+	return out + oobOut, nil
+}
+
+// errorMessage returns the SocketError sent for a message that failed to render.
+func (s *Socket) errorMessage(msg SocketMessage, err error) []byte {
+	socketErr := SocketError{Error: http.StatusText(http.StatusInternalServerError), Target: msg.Target, Action: msg.Action}
+	if s.service.config.DevMode {
+		socketErr.Error = err.Error()
+	}
+
+	b, _ := json.Marshal(socketErr)
+	return b
+}
+
+// newSocketRequest derives the request of a message from the upgrade request.
+func newSocketRequest(ctx context.Context, upgrade *http.Request, c connector.Connector, msg SocketMessage) *http.Request {
+	r := upgrade.Clone(ctx)
+	r.Method = http.MethodPost
+	r.Body = http.NoBody
+	r.Header = upgrade.Header.Clone()
+
+	values := url.Values{}
+	for k, v := range msg.Values {
+		switch vv := v.(type) {
+		case []any:
+			for _, item := range vv {
+				values.Add(k, fmt.Sprint(item))
+			}
+		case nil:
+			values.Set(k, "")
+		default:
+			values.Set(k, fmt.Sprint(vv))
+		}
+	}
+	r.Form = values
+	r.PostForm = values
+
+	setOrDel(r.Header, c.GetTargetHeader(), msg.Target)
+	setOrDel(r.Header, c.GetSelectHeader(), msg.Select)
+	setOrDel(r.Header, c.GetActionHeader(), msg.Action)
+
+	return r
+}
+
+func setOrDel(h http.Header, key, value string) {
+	if value == "" {
+		h.Del(key)
+		return
+	}
+	h.Set(key, value)
+}
+
+// sameOrigin reports whether the request has no Origin header or one with the host of the request.
+func sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+
+	return strings.EqualFold(u.Host, r.Host)
+}
+
+// upgradeWebsocket performs the server side of the WebSocket handshake.
+func upgradeWebsocket(w http.ResponseWriter, r *http.Request, maxSize int64) (*socketConn, error) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return nil, errors.New("websocket upgrade requires GET")
+	}
+
+	if !headerContains(r.Header, "Connection", "upgrade") || !headerContains(r.Header, "Upgrade", "websocket") {
+		http.Error(w, "websocket upgrade required", http.StatusBadRequest)
+		return nil, errors.New("missing websocket upgrade headers")
+	}
+
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(w, "unsupported websocket version", http.StatusUpgradeRequired)
+		return nil, errors.New("unsupported websocket version")
+	}
+
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if key == "" {
+		http.Error(w, "missing websocket key", http.StatusBadRequest)
+		return nil, errors.New("missing websocket key")
+	}
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "websocket is not supported", http.StatusInternalServerError)
+		return nil, errors.New("response writer does not support hijacking")
+	}
+
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, fmt.Errorf("error hijacking connection: %w", err)
+	}
+
+	sum := sha1.Sum([]byte(key + websocketGUID))
+	response := "HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + base64.StdEncoding.EncodeToString(sum[:]) + "\r\n\r\n"
+
+	if _, err = rw.WriteString(response); err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("error writing handshake: %w", err)
+	}
+	if err = rw.Flush(); err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("error writing handshake: %w", err)
+	}
+
+	return &socketConn{conn: conn, reader: rw.Reader, maxSize: maxSize}, nil
+}
+
+// readMessage reads the next text or binary message, answering pings and joining fragmented frames.
+func (c *socketConn) readMessage() ([]byte, error) {
+	var message []byte
+	for {
+		fin, opcode, payload, err := c.readFrame()
+		if err != nil {
+			return nil, err
+		}
+
+		switch opcode {
+		case opPing:
+			if err = c.writeFrame(opPong, payload); err != nil {
+				return nil, err
+			}
+			continue
+		case opPong:
+			continue
+		case opClose:
+			_ = c.writeFrame(opClose, payload)
+			return nil, errSocketClosed
+		case opText, opBinary, opContinuation:
+			message = append(message, payload...)
+			if int64(len(message)) > c.maxSize {
+				_ = c.writeFrame(opClose, closePayload(1009, "message too big"))
+				return nil, errors.New("websocket message too big")
+			}
+			if fin {
+				return message, nil
+			}
+		default:
+			_ = c.writeFrame(opClose, closePayload(1002, "unknown opcode"))
+			return nil, fmt.Errorf("unknown websocket opcode %d", opcode)
+		}
+	}
+}
+
+// readFrame reads a single frame, client frames are always masked.
+// The client has the read timeout to send the frame, a pong answering a ping extends it as well.
+func (c *socketConn) readFrame() (fin bool, opcode byte, payload []byte, err error) {
+	if c.readTimeout > 0 {
+		if err = c.conn.SetReadDeadline(time.Now().Add(c.readTimeout)); err != nil {
+			return false, 0, nil, err
+		}
+	}
+
+	var header [2]byte
+	if _, err = io.ReadFull(c.reader, header[:]); err != nil {
+		return false, 0, nil, err
+	}
+
+	fin = header[0]&0x80 != 0
+	opcode = header[0] & 0x0F
+	masked := header[1]&0x80 != 0
+	length := int64(header[1] & 0x7F)
+
+	// control frames must not be fragmented and carry at most 125 bytes, see RFC 6455 section 5.5
+	if opcode&0x8 != 0 && (!fin || length > 125) {
+		_ = c.writeFrame(opClose, closePayload(1002, "invalid control frame"))
+		return false, 0, nil, errors.New("invalid websocket control frame")
+	}
+
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err = io.ReadFull(c.reader, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = int64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err = io.ReadFull(c.reader, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = int64(binary.BigEndian.Uint64(ext[:]))
+	}
+
+	if !masked {
+		return false, 0, nil, errors.New("websocket client frame is not masked")
+	}
+
+	if length < 0 || length > c.maxSize {
+		return false, 0, nil, errors.New("websocket frame too big")
+	}
+
+	var mask [4]byte
+	if _, err = io.ReadFull(c.reader, mask[:]); err != nil {
+		return false, 0, nil, err
+	}
+
+	payload = make([]byte, length)
+	if _, err = io.ReadFull(c.reader, payload); err != nil {
+		return false, 0, nil, err
+	}
+
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+
+	return fin, opcode, payload, nil
+}
+
+// writeFrame writes a single unfragmented, unmasked frame.
+func (c *socketConn) writeFrame(opcode byte, payload []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	header := []byte{0x80 | opcode}
+	switch length := len(payload); {
+	case length < 126:
+		header = append(header, byte(length))
+	case length <= 0xFFFF:
+		header = append(header, 126, 0, 0)
+		binary.BigEndian.PutUint16(header[2:], uint16(length))
+	default:
+		header = append(header, 127, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(header[2:], uint64(length))
+	}
+
+	if _, err := c.conn.Write(append(header, payload...)); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// keepAlive pings the client at the interval until done is closed, so a connected client is not timed out.
+func (c *socketConn) keepAlive(interval time.Duration, done <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			if err := c.writeFrame(opPing, nil); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func (c *socketConn) close() {
+	_ = c.conn.Close()
+}
+
+func closePayload(code uint16, reason string) []byte {
+	payload := make([]byte, 2, 2+len(reason))
+	binary.BigEndian.PutUint16(payload, code)
+	return append(payload, reason...)
+}
+
+func headerContains(h http.Header, key, token string) bool {
+	for _, value := range h.Values(key) {
+		for _, part := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(part), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,274 @@
+package partial
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/partial-coffee/go-partial/connector"
+)
+
+func TestSocket(t *testing.T) {
+	fsys := &InMemoryFS{
+		Files: map[string]string{
+			"templates/index.html":   `<html><body>{{ child "counter" }}{{ child "status" }}</body></html>`,
+			"templates/counter.html": `<div id="counter" {{ oobSwapIfEnabled "true" }}>{{ .Data.Count }}</div>`,
+			"templates/status.html":  `<p id="status" {{ oobSwapIfEnabled "true" }}>{{ .Data.Status }}</p>`,
+		},
+	}
+
+	svc := NewService(&Config{FS: fsys, Connector: connector.NewHTMX(nil)})
+
+	count := 0
+	socket := svc.NewSocket(nil, func(r *http.Request) *Partial {
+		counter := New("templates/counter.html").ID("counter").SetData(map[string]any{"Count": count})
+		counter.WithAction(func(ctx context.Context, p *Partial, data *Data) (*Partial, error) {
+			if p.GetRequestedAction() == "increment" {
+				count += 1
+				if r.FormValue("by") == "10" {
+					count += 9
+				}
+				p.AddData("Count", count)
+				data.AddOOB(New("templates/status.html").ID("status").SetData(map[string]any{"Status": "saved"}), "")
+			}
+			return p, nil
+		})
+
+		return New("templates/index.html").ID("root").With(counter)
+	})
+
+	server := httptest.NewServer(socket)
+	defer server.Close()
+
+	conn, reader := dialTestSocket(t, server.URL)
+	defer conn.Close()
+
+	writeTestFrame(t, conn, 0x1, []byte(`{"target":"counter","action":"increment"}`))
+	if got := readTestFrame(t, reader); got != `<div id="counter" hx-swap-oob="outerHTML">1</div><p id="status" hx-swap-oob="outerHTML">saved</p>` {
+		t.Errorf("unexpected message %s", got)
+	}
+
+	writeTestFrame(t, conn, 0x1, []byte(`{"target":"counter","action":"increment","values":{"by":10}}`))
+	if got := readTestFrame(t, reader); got != `<div id="counter" hx-swap-oob="outerHTML">11</div><p id="status" hx-swap-oob="outerHTML">saved</p>` {
+		t.Errorf("unexpected message %s", got)
+	}
+
+	writeTestFrame(t, conn, 0x1, []byte(`{"target":"counter"}`))
+	if got := readTestFrame(t, reader); got != `<div id="counter" hx-swap-oob="outerHTML">11</div>` {
+		t.Errorf("unexpected message %s", got)
+	}
+
+	writeTestFrame(t, conn, 0x8, []byte{0x03, 0xE8})
+	if _, err := reader.ReadByte(); err != nil {
+		t.Fatalf("expected close frame, got %v", err)
+	}
+}
+
+func TestSocketRejectsPlainRequests(t *testing.T) {
+	socket := NewService(&Config{}).NewSocket(nil, func(r *http.Request) *Partial { return nil })
+
+	response := httptest.NewRecorder()
+	socket.ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if response.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400, got %d", response.Code)
+	}
+}
+
+func TestSocketOrigin(t *testing.T) {
+	socket := NewService(&Config{}).NewSocket(nil, func(r *http.Request) *Partial { return nil })
+
+	request := httptest.NewRequest(http.MethodGet, "http://app.example/ws", nil)
+	request.Header.Set("Connection", "Upgrade")
+	request.Header.Set("Upgrade", "websocket")
+	request.Header.Set("Origin", "https://evil.example")
+
+	response := httptest.NewRecorder()
+	socket.ServeHTTP(response, request)
+	if response.Code != http.StatusForbidden {
+		t.Errorf("expected status 403 for a cross-origin handshake, got %d", response.Code)
+	}
+
+	server := httptest.NewServer(socket)
+	defer server.Close()
+
+	conn, _ := dialTestSocket(t, server.URL, "Origin: http://test")
+	_ = conn.Close()
+
+	allowAll := NewService(&Config{}).NewSocket(&SocketConfig{CheckOrigin: func(r *http.Request) bool { return true }}, func(r *http.Request) *Partial { return nil })
+	response = httptest.NewRecorder()
+	allowAll.ServeHTTP(response, request)
+	if response.Code == http.StatusForbidden {
+		t.Errorf("expected CheckOrigin to allow the origin")
+	}
+}
+
+func TestSocketRenderError(t *testing.T) {
+	fsys := &InMemoryFS{
+		Files: map[string]string{
+			"templates/counter.html": `<div id="counter">{{ .Data.Count }}</div>`,
+		},
+	}
+
+	socket := NewService(&Config{FS: fsys}).NewSocket(nil, func(r *http.Request) *Partial {
+		return NewID("counter", "templates/counter.html").WithAction(func(ctx context.Context, p *Partial, data *Data) (*Partial, error) {
+			if p.GetRequestedAction() == "fail" {
+				return nil, errors.New("name is required")
+			}
+			data.Data["Count"] = 1
+			return p, nil
+		})
+	})
+
+	server := httptest.NewServer(socket)
+	defer server.Close()
+
+	conn, reader := dialTestSocket(t, server.URL)
+	defer conn.Close()
+
+	// the error is sent to the client and the connection stays open
+	writeTestFrame(t, conn, 0x1, []byte(`{"target":"counter","action":"fail"}`))
+	if got := readTestFrame(t, reader); got != `{"error":"Internal Server Error","target":"counter","action":"fail"}` {
+		t.Errorf("unexpected error message %s", got)
+	}
+
+	writeTestFrame(t, conn, 0x1, []byte(`{"target":"counter"}`))
+	if got := readTestFrame(t, reader); got != `<div id="counter">1</div>` {
+		t.Errorf("unexpected message %s", got)
+	}
+}
+
+func TestSocketControlFrames(t *testing.T) {
+	socket := NewService(&Config{}).NewSocket(nil, func(r *http.Request) *Partial { return nil })
+
+	server := httptest.NewServer(socket)
+	defer server.Close()
+
+	tests := []struct {
+		name    string
+		first   byte
+		payload []byte
+	}{
+		{name: "fragmented ping", first: 0x09, payload: []byte("ping")},
+		{name: "ping over 125 bytes", first: 0x89, payload: make([]byte, 126)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn, reader := dialTestSocket(t, server.URL)
+			defer conn.Close()
+
+			writeTestRawFrame(t, conn, tt.first, tt.payload)
+			if got := readTestFrame(t, reader); got != "\x03\xeainvalid control frame" {
+				t.Errorf("expected a close frame with code 1002, got %q", got)
+			}
+		})
+	}
+}
+
+func TestSocketReadTimeout(t *testing.T) {
+	socket := NewService(&Config{}).NewSocket(&SocketConfig{ReadTimeout: 100 * time.Millisecond}, func(r *http.Request) *Partial { return nil })
+
+	server := httptest.NewServer(socket)
+	defer server.Close()
+
+	conn, reader := dialTestSocket(t, server.URL)
+	defer conn.Close()
+
+	// the pings of the server are not answered, so the connection is closed after the timeout
+	if _, err := io.Copy(io.Discard, reader); err != nil {
+		t.Errorf("expected the server to close the connection, got %v", err)
+	}
+}
+
+func dialTestSocket(t *testing.T, serverURL string, headers ...string) (net.Conn, *bufio.Reader) {
+	t.Helper()
+
+	conn, err := net.Dial("tcp", strings.TrimPrefix(serverURL, "http://"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	_, err = io.WriteString(conn, "GET / HTTP/1.1\r\nHost: test\r\nConnection: Upgrade\r\nUpgrade: websocket\r\n"+
+		"Sec-WebSocket-Version: 13\r\nSec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n"+strings.Join(append(headers, ""), "\r\n")+"\r\n")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("expected status 101, got %d", resp.StatusCode)
+	}
+
+	// the accept value of the example key in RFC 6455
+	if accept := resp.Header.Get("Sec-WebSocket-Accept"); accept != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Fatalf("unexpected accept value %s", accept)
+	}
+
+	return conn, reader
+}
+
+func writeTestFrame(t *testing.T, conn net.Conn, opcode byte, payload []byte) {
+	t.Helper()
+	writeTestRawFrame(t, conn, 0x80|opcode, payload)
+}
+
+// writeTestRawFrame writes a masked frame with the given first byte, holding the FIN bit and the opcode.
+func writeTestRawFrame(t *testing.T, conn net.Conn, first byte, payload []byte) {
+	t.Helper()
+
+	mask := [4]byte{1, 2, 3, 4}
+	frame := []byte{first}
+	if len(payload) < 126 {
+		frame = append(frame, 0x80|byte(len(payload)))
+	} else {
+		frame = append(frame, 0x80|126, byte(len(payload)>>8), byte(len(payload)))
+	}
+	frame = append(frame, mask[:]...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+
+	if _, err := conn.Write(frame); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func readTestFrame(t *testing.T, reader *bufio.Reader) string {
+	t.Helper()
+
+	var header [2]byte
+	if _, err := io.ReadFull(reader, header[:]); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	length := int(header[1] & 0x7F)
+	if length == 126 {
+		var ext [2]byte
+		if _, err := io.ReadFull(reader, ext[:]); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		length = int(binary.BigEndian.Uint16(ext[:]))
+	}
+
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(reader, payload); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	return string(payload)
+}
@@ -0,0 +1,172 @@
+package partial
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/partial-coffee/go-partial/connector"
+)
+
+func TestSetStatus(t *testing.T) {
+	fsys := &InMemoryFS{
+		Files: map[string]string{
+			"templates/layout.html":  `<html>{{ child "content" }}</html>`,
+			"templates/content.html": `<main>{{ child "form" }}</main>`,
+			"templates/form.html":    `<form>{{ .Data.Message }}</form>`,
+		},
+	}
+
+	status := func(code int, message string) func(ctx context.Context, p *Partial, data *Data) (*Partial, error) {
+		return func(ctx context.Context, p *Partial, data *Data) (*Partial, error) {
+			data.Data["Message"] = message
+			data.SetStatus(code)
+			return p, nil
+		}
+	}
+
+	tests := []struct {
+		name     string
+		action   func(ctx context.Context, p *Partial, data *Data) (*Partial, error)
+		headers  map[string]string
+		status   int
+		expected string
+	}{
+		{
+			name:     "default",
+			status:   http.StatusOK,
+			expected: `<html><main><form></form></main></html>`,
+		},
+		{
+			name:     "created",
+			action:   status(http.StatusCreated, "created"),
+			headers:  map[string]string{"HX-Request": "true", "HX-Target": "form"},
+			status:   http.StatusCreated,
+			expected: `<form>created</form>`,
+		},
+		{
+			name:     "validation error in a partial request",
+			action:   status(http.StatusUnprocessableEntity, "name is required"),
+			headers:  map[string]string{"HX-Request": "true", "HX-Target": "form"},
+			status:   http.StatusUnprocessableEntity,
+			expected: `<form>name is required</form>`,
+		},
+		{
+			name:     "not found",
+			action:   status(http.StatusNotFound, "no such item"),
+			headers:  map[string]string{"HX-Request": "true", "HX-Target": "form"},
+			status:   http.StatusNotFound,
+			expected: `<form>no such item</form>`,
+		},
+		{
+			name:     "conflict",
+			action:   status(http.StatusConflict, "changed by someone else"),
+			headers:  map[string]string{"HX-Request": "true", "HX-Target": "form"},
+			status:   http.StatusConflict,
+			expected: `<form>changed by someone else</form>`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := NewID("form", "templates/form.html")
+			if tt.action != nil {
+				form.WithAction(tt.action)
+			}
+			content := NewID("content", "templates/content.html").With(form)
+
+			svc := NewService(&Config{FS: fsys, Connector: connector.NewHTMX(nil)})
+			layout := svc.NewLayout().Set(content).Wrap(NewID("layout", "templates/layout.html"))
+
+			r := httptest.NewRequest(http.MethodPost, "/", nil)
+			for k, v := range tt.headers {
+				r.Header.Set(k, v)
+			}
+
+			w := httptest.NewRecorder()
+			if err := layout.WriteWithRequest(context.Background(), w, r); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if w.Code != tt.status {
+				t.Errorf("expected status %d, got %d", tt.status, w.Code)
+			}
+			if w.Body.String() != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, w.Body.String())
+			}
+		})
+	}
+
+	t.Run("set on the content before wrapping", func(t *testing.T) {
+		content := NewID("content", "templates/content.html").SetStatus(http.StatusCreated).With(NewID("form", "templates/form.html"))
+		layout := NewService(&Config{FS: fsys}).NewLayout().Set(content).Wrap(NewID("layout", "templates/layout.html"))
+
+		w := httptest.NewRecorder()
+		if err := layout.WriteWithRequest(context.Background(), w, httptest.NewRequest(http.MethodGet, "/", nil)); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if w.Code != http.StatusCreated {
+			t.Errorf("expected status %d, got %d", http.StatusCreated, w.Code)
+		}
+	})
+
+	t.Run("reused tree", func(t *testing.T) {
+		form := NewID("form", "templates/form.html").WithAction(func(ctx context.Context, p *Partial, data *Data) (*Partial, error) {
+			if data.Request.URL.Query().Get("name") == "" {
+				data.SetStatus(http.StatusUnprocessableEntity)
+			}
+			return p, nil
+		})
+		root := NewID("content", "templates/content.html").With(form)
+		NewService(&Config{FS: fsys}).NewLayout().Set(root)
+
+		for _, tc := range []struct {
+			url    string
+			status int
+		}{
+			{url: "/", status: http.StatusUnprocessableEntity},
+			{url: "/?name=alice", status: http.StatusOK},
+		} {
+			r := httptest.NewRequest(http.MethodPost, tc.url, nil)
+			r.Header.Set("X-Target", "form")
+
+			w := httptest.NewRecorder()
+			if err := root.WriteWithRequest(context.Background(), w, r); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if w.Code != tc.status {
+				t.Errorf("%s: expected status %d, got %d", tc.url, tc.status, w.Code)
+			}
+		}
+	})
+
+	t.Run("partial", func(t *testing.T) {
+		p := NewID("form", "templates/form.html").SetStatus(http.StatusAccepted)
+		NewService(&Config{FS: fsys}).NewLayout().Set(p)
+
+		w := httptest.NewRecorder()
+		if err := p.WriteWithRequest(context.Background(), w, httptest.NewRequest(http.MethodGet, "/", nil)); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if w.Code != http.StatusAccepted {
+			t.Errorf("expected status %d, got %d", http.StatusAccepted, w.Code)
+		}
+	})
+
+	t.Run("render response", func(t *testing.T) {
+		form := NewID("form", "templates/form.html").WithAction(status(http.StatusUnprocessableEntity, "name is required"))
+		layout := NewService(&Config{FS: fsys}).NewLayout().Set(NewID("content", "templates/content.html").With(form))
+
+		r := httptest.NewRequest(http.MethodPost, "/", nil)
+		r.Header.Set("X-Target", "form")
+
+		out, res, err := layout.RenderResponse(context.Background(), r)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if res.Status != http.StatusUnprocessableEntity || out != `<form>name is required</form>` {
+			t.Errorf("expected status %d with the form, got %d and %q", http.StatusUnprocessableEntity, res.Status, out)
+		}
+	})
+}
@@ -294,7 +294,7 @@ func selectionFunc(p *Partial, data *Data) func() template.HTML {
 
 		partials := p.getSelectionPartials()
 		if partials == nil {
-			p.getLogger().Error("no selection partials found", "id", p.id)
+			p.log(data.Ctx).Error("no selection partials found")
 			return template.HTML(fmt.Sprintf("no selection partials found in parent '%s'", p.id))
 		}
 
@@ -306,15 +306,18 @@ func selectionFunc(p *Partial, data *Data) func() template.HTML {
 		}
 
 		if selectedPartial == nil {
-			p.getLogger().Error("selected partial not found", "id", requestedSelect, "parent", p.id)
+			p.log(data.Ctx).Error("selected partial not found", "id", requestedSelect)
 			return template.HTML(fmt.Sprintf("selected partial '%s' not found in parent '%s'", requestedSelect, p.id))
 		}
 
-		selectedPartial.fs = p.fs
+		selectedPartial.fs = p.getFS()
+		if selectedPartial.parent == nil {
+			selectedPartial.parent = p
+		}
 
 		html, err := selectedPartial.renderSelf(data.Ctx, p.GetRequest())
 		if err != nil {
-			p.getLogger().Error("error rendering selected partial", "id", requestedSelect, "parent", p.id, "error", err)
+			p.log(data.Ctx).Error("error rendering selected partial", "id", requestedSelect, "error", err)
 			return template.HTML(fmt.Sprintf("error rendering selected partial '%s'", requestedSelect))
 		}
 
@@ -325,7 +328,7 @@ func selectionFunc(p *Partial, data *Data) func() template.HTML {
 func childFunc(p *Partial, data *Data) func(id string, vals ...any) template.HTML {
 	return func(id string, vals ...any) template.HTML {
 		if len(vals) > 0 && len(vals)%2 != 0 {
-			p.getLogger().Warn("invalid child data for partial, they come in key-value pairs", "id", id)
+			p.log(data.Ctx).Warn("invalid child data for partial, they come in key-value pairs", "id", id)
 			return template.HTML(fmt.Sprintf("invalid child data for partial '%s'", id))
 		}
 
@@ -333,7 +336,7 @@ func childFunc(p *Partial, data *Data) func(id string, vals ...any) template.HTM
 		for i := 0; i < len(vals); i += 2 {
 			key, ok := vals[i].(string)
 			if !ok {
-				p.getLogger().Warn("invalid child data key for partial, it must be a string", "id", id, "key", vals[i])
+				p.log(data.Ctx).Warn("invalid child data key for partial, it must be a string", "id", id, "key", vals[i])
 				return template.HTML(fmt.Sprintf("invalid child data key for partial '%s', want string, got %T", id, vals[i]))
 			}
 			d[key] = vals[i+1]
@@ -341,7 +344,7 @@ func childFunc(p *Partial, data *Data) func(id string, vals ...any) template.HTM
 
 		html, err := p.renderChildPartial(data.Ctx, id, d)
 		if err != nil {
-			p.getLogger().Error("error rendering partial", "id", id, "error", err)
+			p.log(data.Ctx).Error("error rendering partial", "id", id, "error", err)
 			// Handle error: you can log it and return an empty string or an error message
 			return template.HTML(fmt.Sprintf("error rendering partial '%s': %v", id, err))
 		}
@@ -367,23 +370,50 @@ func childIfFunc(p *Partial, data *Data) func(id string, vals ...any) template.H
 func actionFunc(p *Partial, data *Data) func() template.HTML {
 	return func() template.HTML {
 		if p.templateAction == nil {
-			p.getLogger().Error("no action callback found", "id", p.id)
+			p.log(data.Ctx).Error("no action callback found")
 			return template.HTML(fmt.Sprintf("no action callback found in partial '%s'", p.id))
 		}
 
 		// Use the selector to get the appropriate partial
 		actionPartial, err := p.templateAction(data.Ctx, p, data)
 		if err != nil {
-			p.getLogger().Error("error in selector function", "error", err)
+			p.log(data.Ctx).Error("error in selector function", "error", err)
 			return template.HTML(fmt.Sprintf("error in action function: %v", err))
 		}
 
 		// Render the selected partial instead
 		html, err := actionPartial.renderSelf(data.Ctx, p.GetRequest())
 		if err != nil {
-			p.getLogger().Error("error rendering action partial", "error", err)
+			p.log(data.Ctx).Error("error rendering action partial", "error", err)
 			return template.HTML(fmt.Sprintf("error rendering action partial: %v", err))
 		}
 		return html
 	}
 }
+
+// childrenFunc renders every child added with With in insertion order, OOB and slotted children are skipped.
+func childrenFunc(p *Partial, data *Data) func(vals ...any) template.HTML {
+	return func(vals ...any) template.HTML {
+		var out template.HTML
+		for _, child := range p.getChildren() {
+			if p.isOOB(child.id) || p.isSlotted(child.id) {
+				continue
+			}
+			out += childFunc(p, data)(child.id, vals...)
+		}
+
+		return out
+	}
+}
+
+// childrenOfFunc renders every child of the named slot in insertion order.
+func childrenOfFunc(p *Partial, data *Data) func(slot string, vals ...any) template.HTML {
+	return func(slot string, vals ...any) template.HTML {
+		var out template.HTML
+		for _, id := range p.getSlotIDs(slot) {
+			out += childFunc(p, data)(id, vals...)
+		}
+
+		return out
+	}
+}
@@ -0,0 +1,186 @@
+package partial
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+type (
+	// Tracer starts spans for the steps of a render. It follows the shape of an OpenTelemetry tracer,
+	// so an adapter only has to forward the calls. The spans are:
+	//
+	//	partial.render  every render of a partial, with partial.id, partial.templates and the
+	//	                partial.target, partial.select and partial.action values of the request
+	//	partial.action  the action of a partial
+	//	partial.cache   the template cache lookup, with partial.cache.hit
+	//	partial.parse   the parse of the templates on a cache miss
+	Tracer interface {
+		StartSpan(ctx context.Context, name string, attrs ...Attribute) (context.Context, Span)
+	}
+
+	// Span is a single traced operation.
+	Span interface {
+		SetAttributes(attrs ...Attribute)
+		RecordError(err error)
+		End()
+	}
+
+	// Attribute is a key-value pair describing a span.
+	Attribute struct {
+		Key   string
+		Value any
+	}
+
+	// RecordingTracer keeps the finished spans in memory, it is meant for tests.
+	RecordingTracer struct {
+		mu     sync.Mutex
+		nextID int
+		spans  []RecordedSpan
+	}
+
+	// RecordedSpan is a span finished on a RecordingTracer.
+	RecordedSpan struct {
+		ID         int
+		ParentID   int
+		Name       string
+		Attributes map[string]any
+		Errors     []error
+		Start      time.Time
+		End        time.Time
+	}
+
+	recordingSpan struct {
+		tracer *RecordingTracer
+		span   RecordedSpan
+		once   sync.Once
+	}
+
+	recordingSpanKey struct{}
+
+	noopTracer struct{}
+	noopSpan   struct{}
+)
+
+// Attr returns an attribute.
+func Attr(key string, value any) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+// NewRecordingTracer returns a tracer that records the spans in memory.
+func NewRecordingTracer() *RecordingTracer {
+	return &RecordingTracer{}
+}
+
+// StartSpan starts a span, the span in the context becomes its parent.
+func (t *RecordingTracer) StartSpan(ctx context.Context, name string, attrs ...Attribute) (context.Context, Span) {
+	t.mu.Lock()
+	t.nextID++
+	id := t.nextID
+	t.mu.Unlock()
+
+	span := &recordingSpan{
+		tracer: t,
+		span: RecordedSpan{
+			ID:         id,
+			Name:       name,
+			Attributes: make(map[string]any, len(attrs)),
+			Start:      time.Now(),
+		},
+	}
+	if parent, ok := ctx.Value(recordingSpanKey{}).(*recordingSpan); ok {
+		span.span.ParentID = parent.span.ID
+	}
+	span.SetAttributes(attrs...)
+
+	return context.WithValue(ctx, recordingSpanKey{}, span), span
+}
+
+// Spans returns the finished spans in the order they ended.
+func (t *RecordingTracer) Spans() []RecordedSpan {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return append([]RecordedSpan{}, t.spans...)
+}
+
+// Reset removes the recorded spans.
+func (t *RecordingTracer) Reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.spans = nil
+}
+
+func (s *recordingSpan) SetAttributes(attrs ...Attribute) {
+	s.tracer.mu.Lock()
+	defer s.tracer.mu.Unlock()
+
+	for _, attr := range attrs {
+		s.span.Attributes[attr.Key] = attr.Value
+	}
+}
+
+func (s *recordingSpan) RecordError(err error) {
+	if err == nil {
+		return
+	}
+
+	s.tracer.mu.Lock()
+	defer s.tracer.mu.Unlock()
+
+	s.span.Errors = append(s.span.Errors, err)
+}
+
+func (s *recordingSpan) End() {
+	s.once.Do(func() {
+		s.tracer.mu.Lock()
+		defer s.tracer.mu.Unlock()
+
+		s.span.End = time.Now()
+		s.tracer.spans = append(s.tracer.spans, s.span)
+	})
+}
+
+func (noopTracer) StartSpan(ctx context.Context, _ string, _ ...Attribute) (context.Context, Span) {
+	return ctx, noopSpan{}
+}
+
+func (noopSpan) SetAttributes(...Attribute) {}
+func (noopSpan) RecordError(error)          {}
+func (noopSpan) End()                       {}
+
+func (p *Partial) getTracer() Tracer {
+	if p == nil {
+		return noopTracer{}
+	}
+
+	if p.tracer != nil {
+		return p.tracer
+	}
+
+	if p.parent != nil {
+		return p.parent.getTracer()
+	}
+
+	return noopTracer{}
+}
+
+// startRenderSpan starts the span of a render with the details of the partial and the request.
+func (p *Partial) startRenderSpan(ctx context.Context, r *http.Request) (context.Context, Span) {
+	attrs := []Attribute{
+		Attr("partial.id", p.id),
+		Attr("partial.templates", p.templates),
+	}
+
+	if c := p.getConnector(); c != nil && r != nil {
+		attrs = append(attrs,
+			Attr("partial.target", c.GetTargetValue(r)),
+			Attr("partial.select", c.GetSelectValue(r)),
+			Attr("partial.action", c.GetActionValue(r)),
+		)
+	}
+
+	return p.getTracer().StartSpan(ctx, "partial.render", attrs...)
+}
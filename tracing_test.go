@@ -0,0 +1,136 @@
+package partial
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestTracer(t *testing.T) {
+	fsys := &InMemoryFS{
+		Files: map[string]string{
+			"templates/index.html":   `<main>{{ child "content" }}</main>`,
+			"templates/content.html": `<div>{{ .Data.Name }}</div>`,
+		},
+	}
+
+	build := func() *Partial {
+		return NewID("root", "templates/index.html").
+			WithAction(func(ctx context.Context, p *Partial, data *Data) (*Partial, error) {
+				data.Data["Name"] = "saved"
+				return p, nil
+			}).
+			With(NewID("content", "templates/content.html"))
+	}
+
+	tracer := NewRecordingTracer()
+	svc := NewService(&Config{FS: fsys, UseCache: true, Tracer: tracer})
+
+	t.Run("spans", func(t *testing.T) {
+		tracer.Reset()
+		r, _ := http.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("X-Action", "save")
+		if _, err := svc.NewLayout().Set(build()).RenderWithRequest(context.Background(), r); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		byName := map[string][]RecordedSpan{}
+		byID := map[int]RecordedSpan{}
+		for _, span := range tracer.Spans() {
+			byName[span.Name] = append(byName[span.Name], span)
+			byID[span.ID] = span
+		}
+
+		if len(byName["partial.render"]) != 2 {
+			t.Fatalf("expected 2 render spans, got %d", len(byName["partial.render"]))
+		}
+
+		var root, content RecordedSpan
+		for _, span := range byName["partial.render"] {
+			switch span.Attributes["partial.id"] {
+			case "root":
+				root = span
+			case "content":
+				content = span
+			}
+		}
+
+		if root.ParentID != 0 {
+			t.Errorf("expected the root span to have no parent, got %d", root.ParentID)
+		}
+		if content.ParentID != root.ID {
+			t.Errorf("expected the content span to be a child of the root span")
+		}
+		if content.Attributes["partial.action"] != "save" {
+			t.Errorf("expected the action value on the span, got %v", content.Attributes["partial.action"])
+		}
+
+		actions := byName["partial.action"]
+		if len(actions) != 1 || actions[0].ParentID != root.ID {
+			t.Errorf("expected one action span below the root span, got %+v", actions)
+		}
+
+		if len(byName["partial.cache"]) != 2 {
+			t.Errorf("expected 2 cache spans, got %d", len(byName["partial.cache"]))
+		}
+		if len(byName["partial.parse"]) != 2 {
+			t.Errorf("expected 2 parse spans, got %d", len(byName["partial.parse"]))
+		}
+	})
+
+	t.Run("cache hit", func(t *testing.T) {
+		p := NewID("content", "templates/content.html")
+		p.fs = fsys
+		p.useCache = true
+		p.tracer = tracer
+
+		tracer.Reset()
+		for range 2 {
+			if _, err := p.getOrParseTemplate(context.Background(), "tracing-cache-hit", nil); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		}
+
+		var hits []any
+		parses := 0
+		for _, span := range tracer.Spans() {
+			switch span.Name {
+			case "partial.cache":
+				hits = append(hits, span.Attributes["partial.cache.hit"])
+			case "partial.parse":
+				parses++
+			}
+		}
+
+		if len(hits) != 2 || hits[0] != false || hits[1] != true {
+			t.Errorf("expected a miss and then a hit, got %v", hits)
+		}
+		if parses != 1 {
+			t.Errorf("expected 1 parse, got %d", parses)
+		}
+	})
+
+	t.Run("error", func(t *testing.T) {
+		failed := errors.New("failed")
+		root := NewID("root", "templates/content.html").
+			WithAction(func(ctx context.Context, p *Partial, data *Data) (*Partial, error) {
+				return p, failed
+			})
+
+		tracer.Reset()
+		r, _ := http.NewRequest(http.MethodGet, "/", nil)
+		if _, err := svc.NewLayout().Set(root).RenderWithRequest(context.Background(), r); err == nil {
+			t.Fatal("expected an error")
+		}
+
+		for _, span := range tracer.Spans() {
+			if span.Name != "partial.action" && span.Name != "partial.render" {
+				continue
+			}
+			if len(span.Errors) != 1 || !errors.Is(span.Errors[0], failed) {
+				t.Errorf("expected %s to record the error, got %v", span.Name, span.Errors)
+			}
+		}
+	})
+}
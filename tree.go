@@ -1,22 +1,222 @@
 package partial
 
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// Node describes a partial in the tree returned by Tree.
 type Node struct {
-	ID    string
-	Depth int
-	Nodes []*Node
+	ID                string   `json:"id"`
+	Depth             int      `json:"depth"`
+	Templates         []string `json:"templates,omitempty"`
+	BasePath          string   `json:"basePath,omitempty"`
+	Connector         string   `json:"connector,omitempty"`
+	IsOOB             bool     `json:"isOOB,omitempty"`
+	AlwaysSwapOOB     bool     `json:"alwaysSwapOOB,omitempty"`
+	HasAction         bool     `json:"hasAction,omitempty"`
+	HasTemplateAction bool     `json:"hasTemplateAction,omitempty"`
+	// SelectionKeys are the keys of the selection map in lexical order, Selection holds their partials
+	SelectionKeys    []string `json:"selectionKeys,omitempty"`
+	SelectionDefault string   `json:"selectionDefault,omitempty"`
+	Selection        []*Node  `json:"selection,omitempty"`
+	// Key is the selection key of the partial, it is only set on the nodes in Selection
+	Key   string  `json:"key,omitempty"`
+	Nodes []*Node `json:"nodes,omitempty"`
 }
 
-// Tree returns the tree of partials.
+// Tree returns the tree of partials, children are listed in the order they were added.
 func Tree(p *Partial) *Node {
 	return tree(p, 0)
 }
 
 func tree(p *Partial, depth int) *Node {
-	var out = &Node{ID: p.id, Depth: depth}
+	var out = &Node{
+		ID:                p.id,
+		Depth:             depth,
+		Templates:         p.templates,
+		BasePath:          p.GetBasePath(),
+		AlwaysSwapOOB:     p.alwaysSwapOOB,
+		HasAction:         p.action != nil,
+		HasTemplateAction: p.templateAction != nil,
+	}
+
+	if c := p.getConnector(); c != nil {
+		out.Connector = fmt.Sprintf("%T", c)
+	}
 
-	for _, child := range p.children {
-		out.Nodes = append(out.Nodes, tree(child, depth+1))
+	for _, child := range p.getChildren() {
+		node := tree(child, depth+1)
+		node.IsOOB = p.isOOB(child.id)
+		out.Nodes = append(out.Nodes, node)
+	}
+
+	if p.selection != nil {
+		out.SelectionDefault = p.selection.Default
+		for key := range p.selection.Partials {
+			out.SelectionKeys = append(out.SelectionKeys, key)
+		}
+		sort.Strings(out.SelectionKeys)
+
+		for _, key := range out.SelectionKeys {
+			if selected := p.selection.Partials[key]; selected != nil {
+				node := tree(selected, depth+1)
+				node.Key = key
+				out.Selection = append(out.Selection, node)
+			}
+		}
 	}
 
 	return out
 }
+
+// WriteJSON writes the tree as indented JSON.
+func (n *Node) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(n)
+}
+
+// WriteDOT writes the tree as Graphviz DOT graph.
+// OOB children are drawn with dashed edges and selection partials with dotted edges labelled with their key.
+func (n *Node) WriteDOT(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString("digraph partials {\n")
+	b.WriteString("  node [shape=box, fontname=\"monospace\"];\n")
+
+	var count int
+	var walk func(node *Node) string
+	walk = func(node *Node) string {
+		// partials can appear more than once in a tree, so the graph nodes are numbered
+		name := fmt.Sprintf("n%d", count)
+		count++
+
+		label := node.ID
+		if len(node.Templates) > 0 {
+			label += "\n" + strings.Join(node.Templates, "\n")
+		}
+		attrs := fmt.Sprintf("label=%s", dotQuote(label))
+		if node.HasAction || node.HasTemplateAction {
+			attrs += ", style=bold"
+		}
+		fmt.Fprintf(&b, "  %s [%s];\n", name, attrs)
+
+		for _, child := range node.Nodes {
+			childName := walk(child)
+			if child.IsOOB {
+				fmt.Fprintf(&b, "  %s -> %s [style=dashed, label=\"oob\"];\n", name, childName)
+			} else {
+				fmt.Fprintf(&b, "  %s -> %s;\n", name, childName)
+			}
+		}
+
+		for _, child := range node.Selection {
+			childName := walk(child)
+			label := "select " + child.Key
+			if child.Key == node.SelectionDefault {
+				label += " (default)"
+			}
+			fmt.Fprintf(&b, "  %s -> %s [style=dotted, label=%s];\n", name, childName, dotQuote(label))
+		}
+
+		return name
+	}
+	walk(n)
+
+	b.WriteString("}\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func dotQuote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	s = strings.ReplaceAll(s, "\n", `\n`)
+	return `"` + s + `"`
+}
+
+var treeHTMLTemplate = template.Must(template.New("tree").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Partial tree</title>
+<style>
+body { font-family: monospace; margin: 2em; }
+ul { list-style: none; border-left: 1px solid #ccc; margin: 0; padding-left: 1.5em; }
+li { margin: .4em 0; }
+.id { font-weight: bold; }
+.tag { display: inline-block; padding: 0 .4em; margin-left: .3em; border-radius: 3px; background: #eee; font-size: .85em; }
+.oob { background: #fde2c8; }
+.select { background: #d6e8fa; }
+.action { background: #d9f2d9; }
+.templates { color: #666; }
+</style>
+</head>
+<body>
+<h1>Partial tree</h1>
+<ul>{{ template "node" . }}</ul>
+</body>
+</html>
+{{ define "node" }}<li>
+{{- if .Key }}<span class="tag select">select {{ .Key }}</span> {{ end -}}
+<span class="id">{{ .ID }}</span>
+{{- if .IsOOB }}<span class="tag oob">oob</span>{{ end }}
+{{- if .AlwaysSwapOOB }}<span class="tag oob">always swap</span>{{ end }}
+{{- if .HasAction }}<span class="tag action">action</span>{{ end }}
+{{- if .HasTemplateAction }}<span class="tag action">template action</span>{{ end }}
+{{- if .SelectionDefault }}<span class="tag select">default {{ .SelectionDefault }}</span>{{ end }}
+{{- if .BasePath }}<span class="tag">{{ .BasePath }}</span>{{ end }}
+{{- if .Connector }}<span class="tag">{{ .Connector }}</span>{{ end }}
+{{- with .Templates }} <span class="templates">{{ range $i, $t := . }}{{ if $i }}, {{ end }}{{ $t }}{{ end }}</span>{{ end }}
+{{- if or .Nodes .Selection }}<ul>
+{{- range .Nodes }}{{ template "node" . }}{{ end }}
+{{- range .Selection }}{{ template "node" . }}{{ end -}}
+</ul>{{ end -}}
+</li>
+{{ end }}`))
+
+// WriteHTML writes the tree as a standalone HTML page.
+func (n *Node) WriteHTML(w io.Writer) error {
+	return treeHTMLTemplate.Execute(w, n)
+}
+
+// TreeHandler returns a debug handler that shows the tree of the partial returned by build.
+// The page is served as HTML, the format query parameter selects "json" or "dot" instead.
+// It exposes the structure of the application, so do not mount it in production.
+func TreeHandler(build func(r *http.Request) *Partial) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p := build(r)
+		if p == nil {
+			http.Error(w, "partial is not initialized", http.StatusInternalServerError)
+			return
+		}
+
+		node := Tree(p)
+
+		var err error
+		switch r.URL.Query().Get("format") {
+		case "json":
+			w.Header().Set("Content-Type", "application/json")
+			err = node.WriteJSON(w)
+		case "dot":
+			w.Header().Set("Content-Type", "text/vnd.graphviz; charset=utf-8")
+			err = node.WriteDOT(w)
+		case "", "html":
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			err = node.WriteHTML(w)
+		default:
+			http.Error(w, "unknown format, use html, json or dot", http.StatusBadRequest)
+			return
+		}
+
+		if err != nil {
+			p.getLogger().Error("error writing partial tree", "error", err)
+		}
+	})
+}
@@ -1,7 +1,13 @@
 package partial
 
 import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
+
+	"github.com/partial-coffee/go-partial/connector"
 )
 
 func TestTree(t *testing.T) {
@@ -48,3 +54,106 @@ func TestTree(t *testing.T) {
 		t.Errorf("expected id to be id1, got %s", tr.Nodes[0].Nodes[0].ID)
 	}
 }
+
+func TestTreeExport(t *testing.T) {
+	action := func(ctx context.Context, p *Partial, data *Data) (*Partial, error) { return p, nil }
+
+	build := func(r *http.Request) *Partial {
+		content := NewID("content", "templates/content.html").WithAction(action)
+		content.WithSelectMap("a", map[string]*Partial{
+			"b": NewID("tab-b", "templates/b.html"),
+			"a": NewID("tab-a", "templates/a.html"),
+		})
+
+		return NewID("root", "templates/index.html").
+			SetBasePath("/app").
+			SetConnector(connector.NewHTMX(nil)).
+			With(content).
+			WithOOB(NewID("footer", "templates/footer.html").SetAlwaysSwapOOB(true))
+	}
+
+	tr := Tree(build(nil))
+
+	content := tr.Nodes[0]
+	if !content.HasAction || content.HasTemplateAction || content.IsOOB {
+		t.Errorf("unexpected flags on content: %+v", content)
+	}
+	if content.BasePath != "/app" || content.Connector != "*connector.HTMX" {
+		t.Errorf("expected inherited base path and connector, got %q and %q", content.BasePath, content.Connector)
+	}
+	if strings.Join(content.SelectionKeys, ",") != "a,b" || content.SelectionDefault != "a" {
+		t.Errorf("unexpected selection: %v default %q", content.SelectionKeys, content.SelectionDefault)
+	}
+	if len(content.Selection) != 2 || content.Selection[1].ID != "tab-b" || content.Selection[1].Key != "b" {
+		t.Errorf("unexpected selection nodes: %+v", content.Selection)
+	}
+
+	footer := tr.Nodes[1]
+	if !footer.IsOOB || !footer.AlwaysSwapOOB {
+		t.Errorf("expected footer to be an OOB child that always swaps, got %+v", footer)
+	}
+
+	tests := []struct {
+		format      string
+		contentType string
+		contains    []string
+	}{
+		{
+			format:      "json",
+			contentType: "application/json",
+			contains: []string{
+				`"id": "root"`,
+				`"templates": [` + "\n" + `        "templates/content.html"`,
+				`"selectionKeys": [`,
+				`"isOOB": true`,
+				`"alwaysSwapOOB": true`,
+			},
+		},
+		{
+			format:      "dot",
+			contentType: "text/vnd.graphviz; charset=utf-8",
+			contains: []string{
+				"digraph partials {",
+				`n0 [label="root\ntemplates/index.html"];`,
+				`n1 [label="content\ntemplates/content.html", style=bold];`,
+				`n1 -> n2 [style=dotted, label="select a (default)"];`,
+				`n1 -> n3 [style=dotted, label="select b"];`,
+				`n0 -> n4 [style=dashed, label="oob"];`,
+			},
+		},
+		{
+			format:      "",
+			contentType: "text/html; charset=utf-8",
+			contains: []string{
+				`<span class="id">root</span><span class="tag">/app</span><span class="tag">*connector.HTMX</span>`,
+				`<span class="tag select">select a</span> <span class="id">tab-a</span>`,
+				`<span class="id">footer</span><span class="tag oob">oob</span><span class="tag oob">always swap</span>`,
+			},
+		},
+	}
+
+	handler := TreeHandler(build)
+
+	for _, tt := range tests {
+		t.Run("format "+tt.format, func(t *testing.T) {
+			response := httptest.NewRecorder()
+			handler.ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/debug/tree?format="+tt.format, nil))
+
+			if got := response.Header().Get("Content-Type"); got != tt.contentType {
+				t.Errorf("expected content type %q, got %q", tt.contentType, got)
+			}
+
+			for _, s := range tt.contains {
+				if !strings.Contains(response.Body.String(), s) {
+					t.Errorf("expected output to contain %q, got:\n%s", s, response.Body.String())
+				}
+			}
+		})
+	}
+
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/debug/tree?format=svg", nil))
+	if response.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d for an unknown format, got %d", http.StatusBadRequest, response.Code)
+	}
+}
@@ -0,0 +1,72 @@
+package partial
+
+import (
+	"context"
+	"fmt"
+)
+
+// Typed is a partial with typed props, available as .Props in the templates.
+// It embeds the Partial, so it can be configured and added to a tree like any other partial;
+// the map based Data, Layout and Service fields keep working alongside the props.
+type Typed[T any] struct {
+	*Partial
+}
+
+// NewTyped creates a new partial with typed props.
+func NewTyped[T any](templates ...string) *Typed[T] {
+	var props T
+	return &Typed[T]{Partial: New(templates...).setProps(props)}
+}
+
+// NewTypedID creates a new partial with typed props and the provided ID.
+func NewTypedID[T any](id string, templates ...string) *Typed[T] {
+	t := NewTyped[T](templates...)
+	t.ID(id)
+	return t
+}
+
+// SetProps sets the props of the partial.
+func (t *Typed[T]) SetProps(props T) *Typed[T] {
+	t.setProps(props)
+	return t
+}
+
+// Props returns the props of the partial.
+func (t *Typed[T]) Props() T {
+	props, _ := t.props.(T)
+	return props
+}
+
+// WithPropsAction adds a callback action that receives a copy of the props, changes to the props are visible to the
+// templates of the current render only, the props of the partial are not changed.
+func (t *Typed[T]) WithPropsAction(action func(ctx context.Context, p *Partial, props *T, data *Data) (*Partial, error)) *Typed[T] {
+	t.WithAction(func(ctx context.Context, p *Partial, data *Data) (*Partial, error) {
+		props, ok := p.props.(T)
+		if !ok {
+			return p, fmt.Errorf("partial '%s' has props of type %T, want %T", p.id, p.props, props)
+		}
+
+		next, err := action(ctx, p, &props, data)
+		data.Props = props
+
+		return next, err
+	})
+
+	return t
+}
+
+// PropsOf returns the props of the partial being rendered, it returns false if they are not of type T.
+func PropsOf[T any](data *Data) (T, bool) {
+	if data == nil {
+		var zero T
+		return zero, false
+	}
+
+	props, ok := data.Props.(T)
+	return props, ok
+}
+
+func (p *Partial) setProps(props any) *Partial {
+	p.props = props
+	return p
+}
@@ -0,0 +1,65 @@
+package partial
+
+import (
+	"context"
+	"net/http"
+	"testing"
+)
+
+type articleProps struct {
+	Title string
+	Tags  []string
+}
+
+func TestTypedProps(t *testing.T) {
+	fsys := &InMemoryFS{
+		Files: map[string]string{
+			"templates/index.html":   `<html><body>{{ child "article" }}</body></html>`,
+			"templates/article.html": `<h1>{{ .Props.Title }}</h1>{{ range .Props.Tags }}<i>{{ . }}</i>{{ end }}<p>{{ .Data.Extra }}</p>`,
+		},
+	}
+
+	svc := NewService(&Config{FS: fsys})
+
+	article := NewTypedID[articleProps]("article", "templates/article.html").
+		SetProps(articleProps{Title: "Hello", Tags: []string{"go"}}).
+		WithPropsAction(func(ctx context.Context, p *Partial, props *articleProps, data *Data) (*Partial, error) {
+			props.Tags = append(props.Tags, "templates")
+
+			if got, ok := PropsOf[articleProps](data); !ok || got.Title != "Hello" {
+				t.Errorf("expected props in data, got %+v", got)
+			}
+
+			return p, nil
+		})
+	article.AddData("Extra", "map data")
+
+	if article.Props().Title != "Hello" {
+		t.Errorf("expected title Hello, got %s", article.Props().Title)
+	}
+
+	p := New("templates/index.html").ID("root").With(article.Partial)
+
+	// the changes of the action apply to a single render, the same tree renders the same for every request
+	for i := 0; i < 3; i++ {
+		request, _ := http.NewRequest(http.MethodGet, "/", nil)
+		request.Header.Set("X-Target", "article")
+		out, err := svc.NewLayout().Set(p).RenderWithRequest(context.Background(), request)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		expected := "<h1>Hello</h1><i>go</i><i>templates</i><p>map data</p>"
+		if string(out) != expected {
+			t.Errorf("request %d: expected %s, got %s", i+1, expected, out)
+		}
+	}
+
+	if tags := article.Props().Tags; len(tags) != 1 {
+		t.Errorf("expected the props of the partial to be unchanged, got %v", tags)
+	}
+
+	if _, ok := PropsOf[string](&Data{Props: articleProps{}}); ok {
+		t.Error("expected PropsOf with the wrong type to fail")
+	}
+}